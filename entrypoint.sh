@@ -10,7 +10,10 @@ until pg_isready -h db -U postgres -d booking -t 1; do
 done
 
 echo "Applying database migrations..."
-psql -h db -U postgres -d booking -f /app/migrations/001_init.sql
+for migration in /app/migrations/*.sql; do
+  echo "Applying $migration"
+  psql -h db -U postgres -d booking -f "$migration"
+done
 
 echo "Starting application..."
 exec ./booking-system
\ No newline at end of file
@@ -32,28 +32,37 @@ func AdminHandler(w http.ResponseWriter, r *http.Request) {
 		managers = append(managers, m)
 	}
 
-	rows, err = models.DB.Query("SELECT id, name FROM booking_items")
+	items, err := listBookingItems(models.DB, itemFilter{})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
-	var items []models.BookingItem
-	for rows.Next() {
-		var i models.BookingItem
-		rows.Scan(&i.ID, &i.Name)
-		items = append(items, i)
+	locations, err := loadLocationTree(models.DB)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	var settings models.SystemSettings
-	models.DB.QueryRow("SELECT slot_duration_minutes, day_start_time, day_end_time FROM system_settings LIMIT 1").
-		Scan(&settings.SlotDurationMinutes, &settings.DayStartTime, &settings.DayEndTime)
+	models.DB.QueryRow(`
+		SELECT slot_duration_minutes, day_start_time, day_end_time,
+		       booking_window_days, min_booking_hours, max_daily_bookings,
+		       waitlist_mode, waitlist_offer_minutes, hold_minutes,
+		       check_in_required, check_in_window_minutes, no_show_grace_minutes, no_show_limit, no_show_window_days,
+		       free_cancel_hours, late_cancel_action
+		FROM system_settings LIMIT 1
+	`).Scan(&settings.SlotDurationMinutes, &settings.DayStartTime, &settings.DayEndTime,
+		&settings.BookingWindowDays, &settings.MinBookingHours, &settings.MaxDailyBookings,
+		&settings.WaitlistMode, &settings.WaitlistOfferMinutes, &settings.HoldMinutes,
+		&settings.CheckInRequired, &settings.CheckInWindowMinutes, &settings.NoShowGraceMinutes, &settings.NoShowLimit, &settings.NoShowWindowDays,
+		&settings.FreeCancelHours, &settings.LateCancelAction)
 
 	models.Tmpl.ExecuteTemplate(w, "admin.html", map[string]interface{}{
-		"Managers": managers,
-		"Items":    items,
-		"Settings": settings,
+		"Managers":  managers,
+		"Items":     items,
+		"Settings":  settings,
+		"Locations": flattenLocations(locations, ""),
 	})
 }
 
@@ -61,48 +70,41 @@ func ApiCreateBookingItemHandler(w http.ResponseWriter, r *http.Request) {
 	session, _ := models.Store.Get(r, "session")
 	role, ok := session.Values["role"].(string)
 	if !ok || role != "admin" {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
 		return
 	}
 
-	var item struct {
-		Name string `json:"name"`
-	}
-
-	// Добавляем проверку тела запроса
-	if r.Body == nil {
-		http.Error(w, `{"error":"Empty request body"}`, http.StatusBadRequest)
+	var input itemInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid JSON"})
 		return
 	}
 
-	defer r.Body.Close()
-
-	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
-		http.Error(w, `{"error":"Invalid JSON"}`, http.StatusBadRequest)
+	item := newBookingItem()
+	if err := input.apply(&item); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
-
-	// Добавляем проверку имени
-	if item.Name == "" {
-		http.Error(w, `{"error":"Name is required"}`, http.StatusBadRequest)
+	if err := validateBookingItem(item); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
 
-	// Всегда устанавливаем Content-Type перед записью ответа
-	w.Header().Set("Content-Type", "application/json")
-
-	_, err := models.DB.Exec("INSERT INTO booking_items (name) VALUES ($1)", item.Name)
-	if err != nil {
+	if err := insertBookingItem(models.DB, &item); err != nil {
+		if isUniqueViolation(err) {
+			respondWithJSON(w, http.StatusConflict, map[string]string{"error": "Item with this name already exists"})
+			return
+		}
+		if isForeignKeyViolation(err) {
+			respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Location not found"})
+			return
+		}
 		log.Printf("Database error: %v", err)
-		http.Error(w, `{"error":"Database error"}`, http.StatusInternalServerError)
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": "Database error"})
 		return
 	}
 
-	// Возвращаем JSON-ответ
-	json.NewEncoder(w).Encode(map[string]string{
-		"status":  "success",
-		"message": "Item created successfully",
-	})
+	respondWithJSON(w, http.StatusCreated, item)
 }
 
 func ApiDeleteBookingItemHandler(w http.ResponseWriter, r *http.Request) {
@@ -146,11 +148,25 @@ func ApiUpdateSettingsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Чтение и парсинг тела запроса
+	// Чтение и парсинг тела запроса. Правила бронирования необязательны:
+	// если поле не передано, сохраняется текущее значение
 	var settings struct {
-		SlotDurationMinutes int    `json:"slot_duration_minutes"`
-		DayStartTime        string `json:"day_start_time"`
-		DayEndTime          string `json:"day_end_time"`
+		SlotDurationMinutes int     `json:"slot_duration_minutes"`
+		DayStartTime        string  `json:"day_start_time"`
+		DayEndTime          string  `json:"day_end_time"`
+		BookingWindowDays   *int    `json:"booking_window_days"`
+		MinBookingHours     *int    `json:"min_booking_hours"`
+		MaxDailyBookings    *int    `json:"max_daily_bookings"`
+		WaitlistMode        *string `json:"waitlist_mode"`
+		WaitlistOfferMin    *int    `json:"waitlist_offer_minutes"`
+		HoldMinutes         *int    `json:"hold_minutes"`
+		CheckInRequired     *bool   `json:"check_in_required"`
+		CheckInWindowMin    *int    `json:"check_in_window_minutes"`
+		NoShowGraceMin      *int    `json:"no_show_grace_minutes"`
+		NoShowLimit         *int    `json:"no_show_limit"`
+		NoShowWindowDays    *int    `json:"no_show_window_days"`
+		FreeCancelHours     *int    `json:"free_cancel_hours"`
+		LateCancelAction    *string `json:"late_cancel_action"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
@@ -158,19 +174,126 @@ func ApiUpdateSettingsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if settings.BookingWindowDays == nil {
+		settings.BookingWindowDays = &models.BookingWindowDays
+	}
+	if settings.MinBookingHours == nil {
+		settings.MinBookingHours = &models.MinBookingHours
+	}
+	if settings.MaxDailyBookings == nil {
+		settings.MaxDailyBookings = &models.MaxDailyBookings
+	}
+	if settings.WaitlistMode == nil {
+		settings.WaitlistMode = &models.WaitlistMode
+	}
+	if settings.WaitlistOfferMin == nil {
+		settings.WaitlistOfferMin = &models.WaitlistOfferMinutes
+	}
+	if settings.HoldMinutes == nil {
+		settings.HoldMinutes = &models.HoldMinutes
+	}
+	if settings.CheckInRequired == nil {
+		settings.CheckInRequired = &models.CheckInRequired
+	}
+	if settings.CheckInWindowMin == nil {
+		settings.CheckInWindowMin = &models.CheckInWindowMinutes
+	}
+	if settings.NoShowGraceMin == nil {
+		settings.NoShowGraceMin = &models.NoShowGraceMinutes
+	}
+	if settings.NoShowLimit == nil {
+		settings.NoShowLimit = &models.NoShowLimit
+	}
+	if settings.NoShowWindowDays == nil {
+		settings.NoShowWindowDays = &models.NoShowWindowDays
+	}
+	if settings.FreeCancelHours == nil {
+		settings.FreeCancelHours = &models.FreeCancelHours
+	}
+	if settings.LateCancelAction == nil {
+		settings.LateCancelAction = &models.LateCancelAction
+	}
+
 	// Валидация данных
 	if settings.SlotDurationMinutes <= 0 {
 		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Slot duration must be positive"})
 		return
 	}
+	if *settings.BookingWindowDays <= 0 {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Booking window must be positive"})
+		return
+	}
+	if *settings.MinBookingHours < 0 {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Minimum booking hours cannot be negative"})
+		return
+	}
+	if *settings.MaxDailyBookings <= 0 {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Daily booking limit must be positive"})
+		return
+	}
+	if *settings.WaitlistMode != "auto" && *settings.WaitlistMode != "offer" {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Waitlist mode must be 'auto' or 'offer'"})
+		return
+	}
+	if *settings.WaitlistOfferMin <= 0 {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Waitlist offer time must be positive"})
+		return
+	}
+	if *settings.HoldMinutes <= 0 {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Hold time must be positive"})
+		return
+	}
+	if *settings.CheckInWindowMin < 0 || *settings.NoShowGraceMin < 0 {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Check-in times cannot be negative"})
+		return
+	}
+	if *settings.NoShowLimit < 0 {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "No-show limit cannot be negative"})
+		return
+	}
+	if *settings.NoShowWindowDays <= 0 {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "No-show window must be positive"})
+		return
+	}
+	if *settings.FreeCancelHours < 0 {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Free cancellation hours cannot be negative"})
+		return
+	}
+	if !lateCancelActions[*settings.LateCancelAction] {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Late cancel action must be 'penalty' or 'refuse'"})
+		return
+	}
 
 	// Обновление в базе данных
 	_, err := models.DB.Exec(`
         UPDATE system_settings 
         SET slot_duration_minutes = $1, 
             day_start_time = $2, 
-            day_end_time = $3
-    `, settings.SlotDurationMinutes, settings.DayStartTime, settings.DayEndTime)
+            day_end_time = $3,
+            booking_window_days = $4,
+            min_booking_hours = $5,
+            max_daily_bookings = $6,
+            waitlist_mode = $7,
+            waitlist_offer_minutes = $8,
+            hold_minutes = $9,
+            check_in_window_minutes = $10,
+            no_show_grace_minutes = $11,
+            no_show_limit = $12,
+            no_show_window_days = $13,
+            free_cancel_hours = $14,
+            late_cancel_action = $15,
+            check_in_required = $16,
+            check_in_required_since = CASE
+                WHEN NOT $16 THEN NULL
+                WHEN check_in_required THEN check_in_required_since
+                ELSE NOW()
+            END,
+            updated_at = NOW()
+    `, settings.SlotDurationMinutes, settings.DayStartTime, settings.DayEndTime,
+		*settings.BookingWindowDays, *settings.MinBookingHours, *settings.MaxDailyBookings,
+		*settings.WaitlistMode, *settings.WaitlistOfferMin, *settings.HoldMinutes,
+		*settings.CheckInWindowMin, *settings.NoShowGraceMin, *settings.NoShowLimit, *settings.NoShowWindowDays,
+		*settings.FreeCancelHours, *settings.LateCancelAction, *settings.CheckInRequired)
 
 	if err != nil {
 		log.Printf("Database error: %v", err)
@@ -182,15 +305,41 @@ func ApiUpdateSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	models.SlotDuration = settings.SlotDurationMinutes
 	models.DayStart = settings.DayStartTime
 	models.DayEnd = settings.DayEndTime
+	models.BookingWindowDays = *settings.BookingWindowDays
+	models.MinBookingHours = *settings.MinBookingHours
+	models.MaxDailyBookings = *settings.MaxDailyBookings
+	models.WaitlistMode = *settings.WaitlistMode
+	models.WaitlistOfferMinutes = *settings.WaitlistOfferMin
+	models.HoldMinutes = *settings.HoldMinutes
+	models.CheckInRequired = *settings.CheckInRequired
+	models.CheckInWindowMinutes = *settings.CheckInWindowMin
+	models.NoShowGraceMinutes = *settings.NoShowGraceMin
+	models.NoShowLimit = *settings.NoShowLimit
+	models.NoShowWindowDays = *settings.NoShowWindowDays
+	models.FreeCancelHours = *settings.FreeCancelHours
+	models.LateCancelAction = *settings.LateCancelAction
 
 	// Успешный ответ
 	respondWithJSON(w, http.StatusOK, map[string]interface{}{
 		"status":  "success",
 		"message": "Settings updated successfully",
 		"data": map[string]interface{}{
-			"slot_duration_minutes": settings.SlotDurationMinutes,
-			"day_start_time":        settings.DayStartTime,
-			"day_end_time":          settings.DayEndTime,
+			"slot_duration_minutes":   settings.SlotDurationMinutes,
+			"day_start_time":          settings.DayStartTime,
+			"day_end_time":            settings.DayEndTime,
+			"booking_window_days":     models.BookingWindowDays,
+			"min_booking_hours":       models.MinBookingHours,
+			"max_daily_bookings":      models.MaxDailyBookings,
+			"waitlist_mode":           models.WaitlistMode,
+			"waitlist_offer_minutes":  models.WaitlistOfferMinutes,
+			"hold_minutes":            models.HoldMinutes,
+			"check_in_required":       models.CheckInRequired,
+			"check_in_window_minutes": models.CheckInWindowMinutes,
+			"no_show_grace_minutes":   models.NoShowGraceMinutes,
+			"no_show_limit":           models.NoShowLimit,
+			"no_show_window_days":     models.NoShowWindowDays,
+			"free_cancel_hours":       models.FreeCancelHours,
+			"late_cancel_action":      models.LateCancelAction,
 		},
 	})
 }
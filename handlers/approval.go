@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"booking-system/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// pendingBooking — заявка, ожидающая решения менеджера
+type pendingBooking struct {
+	UserID       string
+	Participants int
+	HoldsSeats   bool
+	ItemName     string
+	Date         string
+	StartTime    string
+	Started      bool
+}
+
+// lockPendingBooking блокирует заявку bookingID, ожидающую подтверждения
+func lockPendingBooking(tx *sql.Tx, bookingID string) (*pendingBooking, error) {
+	var p pendingBooking
+	err := tx.QueryRow(`
+		SELECT b.user_id, b.participants, bi.pending_holds_seats, bi.name,
+		       to_char(bs.date, 'YYYY-MM-DD'), bs.start_time::text, `+slotStartsAtSQL+` <= NOW()
+		FROM bookings b
+		JOIN booking_slots bs ON b.slot_id = bs.id
+		JOIN booking_items bi ON bs.item_id = bi.id
+		WHERE b.id = $1 AND b.status = 'pending'
+		FOR UPDATE OF b
+	`, bookingID).Scan(&p.UserID, &p.Participants, &p.HoldsSeats, &p.ItemName,
+		&p.Date, &p.StartTime, &p.Started)
+	if err == sql.ErrNoRows {
+		return nil, &bookingError{Status: http.StatusNotFound, Message: "Pending booking not found"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func ApiApprovalQueueHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	role, ok := session.Values["role"].(string)
+	if !ok || (role != "admin" && role != "manager") {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	// Очередь упорядочена по времени подачи заявки
+	rows, err := models.DB.Query(`
+		SELECT b.id, b.user_id, u.login, u.full_name, bi.id, bi.name, bs.id,
+		       to_char(bs.date, 'YYYY-MM-DD'), bs.start_time::text, ` + bookingEndTimeSQL + `,
+		       b.participants, b.status, b.created_at
+		FROM bookings b
+		JOIN booking_slots bs ON b.slot_id = bs.id
+		JOIN booking_items bi ON bs.item_id = bi.id
+		JOIN users u ON b.user_id = u.id
+		WHERE b.status = 'pending'
+		ORDER BY b.created_at
+	`)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	bookings := []models.BookingDetails{}
+	for rows.Next() {
+		var b models.BookingDetails
+		rows.Scan(&b.ID, &b.UserID, &b.UserLogin, &b.UserFullName, &b.ItemID, &b.ItemName, &b.SlotID,
+			&b.Date, &b.StartTime, &b.EndTime, &b.Participants, &b.Status, &b.CreatedAt)
+		bookings = append(bookings, b)
+	}
+
+	respondWithJSON(w, http.StatusOK, bookings)
+}
+
+func ApiApproveBookingHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	role, ok := session.Values["role"].(string)
+	if !ok || (role != "admin" && role != "manager") {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+	actorID, _ := session.Values["user_id"].(string)
+
+	vars := mux.Vars(r)
+	bookingID := vars["id"]
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	// Слоты заявки, затем объект и сама заявка блокируются в том же порядке, что и при бронировании,
+	// чтобы встречные бронирования и подтверждения не приводили к взаимоблокировке
+	slotIDs, err := bookingSlotIDs(tx, bookingID)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	_, err = tx.Exec(`SELECT id FROM booking_slots WHERE id = ANY($1) ORDER BY id FOR UPDATE`, pq.Array(slotIDs))
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	_, err = tx.Exec(`
+		SELECT 1 FROM booking_items bi
+		WHERE bi.id IN (SELECT item_id FROM booking_slots WHERE id = ANY($1))
+		  AND (bi.buffer_before_minutes > 0 OR bi.buffer_after_minutes > 0)
+		FOR UPDATE
+	`, pq.Array(slotIDs))
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	p, err := lockPendingBooking(tx, bookingID)
+	if err != nil {
+		respondBookingError(w, err)
+		return
+	}
+	if p.Started {
+		respondWithJSON(w, http.StatusConflict, map[string]string{"error": "Slot has already started"})
+		return
+	}
+
+	// Слоты заявки должны быть по-прежнему открыты, а буферы объекта вокруг них свободны: заявка,
+	// не занимавшая места, не мешала бронировать соседние слоты. Такая заявка подтверждается
+	// только при наличии свободных мест во всех её слотах
+	for _, slotID := range slotIDs {
+		var isOpen, bufferTaken bool
+		var remaining int
+		err = tx.QueryRow(`
+			SELECT `+slotBaseOpenSQL+`, `+remainingSeatsSQL+`,
+			       EXISTS (`+bufferConflictsSQL+` AND b.id::text <> $2)
+			FROM booking_slots bs WHERE bs.id = $1
+		`, slotID, bookingID).Scan(&isOpen, &remaining, &bufferTaken)
+		if err != nil {
+			respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if !isOpen {
+			respondWithJSON(w, http.StatusConflict, map[string]string{"error": "Slot is not available"})
+			return
+		}
+		if bufferTaken {
+			respondWithJSON(w, http.StatusConflict, map[string]string{
+				"error": "Slot is too close to another booking: setup or cleanup time is required in between",
+			})
+			return
+		}
+		if !p.HoldsSeats && remaining < p.Participants {
+			respondWithJSON(w, http.StatusConflict, map[string]string{
+				"error": fmt.Sprintf("Not enough seats: %d left", remaining),
+			})
+			return
+		}
+	}
+
+	_, err = tx.Exec("UPDATE bookings SET status = 'confirmed', updated_at = NOW() WHERE id = $1", bookingID)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := logBookingEvent(tx, bookingID, "approved", nil, nil, actorID); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	message := fmt.Sprintf("Your booking of %s on %s at %s has been approved.", p.ItemName, p.Date, p.StartTime)
+	if err := notifyUser(tx, p.UserID, message); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func ApiRejectBookingHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	role, ok := session.Values["role"].(string)
+	if !ok || (role != "admin" && role != "manager") {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+	actorID, _ := session.Values["user_id"].(string)
+
+	vars := mux.Vars(r)
+	bookingID := vars["id"]
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	p, err := lockPendingBooking(tx, bookingID)
+	if err != nil {
+		respondBookingError(w, err)
+		return
+	}
+
+	var bundleBookingID *string
+	err = tx.QueryRow(`
+		UPDATE bookings SET status = 'rejected', reject_reason = NULLIF($2, ''), updated_at = NOW()
+		WHERE id = $1
+		RETURNING bundle_booking_id
+	`, bookingID, req.Reason).Scan(&bundleBookingID)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Комплект бронируется целиком: без отклонённого объекта остальные бронирования комплекта отменяются
+	if bundleBookingID != nil {
+		if err := cancelBundleSiblings(tx, *bundleBookingID, bookingID, "Bundle booking rejected"); err != nil {
+			respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := logBookingEvent(tx, bookingID, "rejected", nil, nil, actorID); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	message := fmt.Sprintf("Your booking of %s on %s at %s has been rejected.", p.ItemName, p.Date, p.StartTime)
+	if req.Reason != "" {
+		message = fmt.Sprintf("Your booking of %s on %s at %s has been rejected: %s", p.ItemName, p.Date, p.StartTime, req.Reason)
+	}
+	if err := notifyUser(tx, p.UserID, message); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if p.HoldsSeats {
+		if err := promoteBookingWaitlists(tx, bookingID); err != nil {
+			respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func ApiUpdateItemApprovalHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	role, ok := session.Values["role"].(string)
+	if !ok || role != "admin" {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	vars := mux.Vars(r)
+	itemID := vars["id"]
+
+	var req struct {
+		RequiresApproval  bool  `json:"requires_approval"`
+		PendingHoldsSeats *bool `json:"pending_holds_seats"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	// Уже поданные заявки остаются в очереди и решаются менеджером как обычно
+	result, err := models.DB.Exec(`
+		UPDATE booking_items
+		SET requires_approval = $2, pending_holds_seats = COALESCE($3, pending_holds_seats)
+		WHERE id = $1
+	`, itemID, req.RequiresApproval, req.PendingHoldsSeats)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Item not found"})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
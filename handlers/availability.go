@@ -0,0 +1,117 @@
+package handlers
+
+import "fmt"
+
+// takenSeatsSQL — количество мест, занятых в слоте bs бронированиями,
+// действующими предложениями из листа ожидания и временными удержаниями.
+// Заявки, ожидающие подтверждения, занимают места, только если так настроено у объекта
+const takenSeatsSQL = `(
+	(SELECT COALESCE(SUM(b.participants), 0)
+	 FROM bookings b
+	 WHERE ` + bookingCoversSlotSQL + `
+	   AND (b.status IN ('confirmed', 'completed')
+	        OR (b.status = 'pending' AND (SELECT i.pending_holds_seats FROM booking_items i WHERE i.id = bs.item_id))))
+	+
+	(SELECT COALESCE(SUM(wl.participants), 0)
+	 FROM waitlist_entries wl
+	 WHERE wl.slot_id = bs.id AND wl.status = 'offered' AND wl.offer_expires_at > NOW())
+	+
+	(SELECT COALESCE(SUM(h.participants), 0)
+	 FROM slot_holds h
+	 WHERE h.slot_id = bs.id AND h.expires_at > NOW())
+)`
+
+// bookingCoversSlotSQL — бронирование b занимает слот bs: как основной слот или как один из сегментов
+const bookingCoversSlotSQL = `(b.slot_id = bs.id OR b.id IN (SELECT seg.booking_id FROM booking_segments seg WHERE seg.slot_id = bs.id))`
+
+// bookingEndsAtSQL и bookingEndTimeSQL — конец бронирования b с основным слотом bs с учётом сегментов
+const (
+	bookingEndsAtSQL = `COALESCE((
+		SELECT MAX(es.ends_at) FROM booking_segments seg JOIN booking_slots es ON seg.slot_id = es.id
+		WHERE seg.booking_id = b.id), ` + slotEndsAtSQL + `)`
+	bookingEndTimeSQL = `COALESCE((
+		SELECT MAX(es.end_time) FROM booking_segments seg JOIN booking_slots es ON seg.slot_id = es.id
+		WHERE seg.booking_id = b.id), bs.end_time)::text`
+)
+
+// remainingSeatsSQL — количество свободных мест в слоте bs
+const remainingSeatsSQL = `(bs.max_participants - ` + takenSeatsSQL + `)`
+
+// slotStartsAtSQL и slotEndsAtSQL — начало и конец слота bs как момент времени (TIMESTAMPTZ) с учётом пояса объекта.
+// Сравнивать их, как и другие моменты времени, нужно с NOW()
+const (
+	slotStartsAtSQL = `bs.starts_at`
+	slotEndsAtSQL   = `bs.ends_at`
+)
+
+// notClosedSQL — слот bs не попадает ни под одно закрытие своего объекта или всей системы
+const notClosedSQL = `NOT EXISTS (
+	SELECT 1 FROM closures c
+	WHERE (c.item_id IS NULL OR c.item_id = bs.item_id)
+	  AND c.starts_at < ` + slotEndsAtSQL + ` AND c.ends_at > ` + slotStartsAtSQL + `
+)`
+
+// withinScheduleSQL — слот bs укладывается в расписание своего объекта.
+// Для объектов без расписания проверка не ограничивает слоты: day_start/day_end из system_settings
+// задают только время, в которое для них генерируются слоты
+const withinScheduleSQL = `(
+	NOT EXISTS (SELECT 1 FROM item_schedule_days sd WHERE sd.item_id = bs.item_id)
+	OR (
+		EXISTS (
+			SELECT 1 FROM item_schedule_intervals si
+			WHERE si.item_id = bs.item_id AND si.weekday = EXTRACT(DOW FROM bs.date)
+			  AND NOT si.is_break AND si.start_time <= bs.start_time AND si.end_time >= bs.end_time
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM item_schedule_intervals si
+			WHERE si.item_id = bs.item_id AND si.weekday = EXTRACT(DOW FROM bs.date)
+			  AND si.is_break AND si.start_time < bs.end_time AND si.end_time > bs.start_time
+		)
+	)
+)`
+
+// withinLocationHoursSQL — слот bs укладывается в часы работы всех узлов иерархии над его объектом.
+// Узлы без часов работы время не ограничивают
+const withinLocationHoursSQL = `NOT EXISTS (
+	SELECT 1 FROM location_ancestors((SELECT i.location_id FROM booking_items i WHERE i.id = bs.item_id)) AS a(id)
+	WHERE EXISTS (SELECT 1 FROM location_hours lh WHERE lh.location_id = a.id)
+	  AND NOT EXISTS (
+		SELECT 1 FROM location_hours lh
+		WHERE lh.location_id = a.id AND lh.weekday = EXTRACT(DOW FROM bs.date)
+		  AND lh.open_time <= bs.start_time AND lh.close_time >= bs.end_time
+	  )
+)`
+
+// bufferConflictsSQL — действующие бронирования b других слотов объекта bs, которые вместе с буферами
+// объекта пересекаются со слотом bs вместе с его буферами. Бронирования, уже занимающие сам слот bs
+// (например, диапазоны из нескольких слотов), конфликтом не считаются
+const bufferConflictsSQL = `
+	SELECT b.id
+	FROM booking_slots os
+	JOIN booking_items bf ON bf.id = os.item_id
+	JOIN bookings b ON (b.slot_id = os.id OR b.id IN (SELECT seg.booking_id FROM booking_segments seg WHERE seg.slot_id = os.id))
+	WHERE os.item_id = bs.item_id AND os.id <> bs.id
+	  AND (bf.buffer_before_minutes > 0 OR bf.buffer_after_minutes > 0)
+	  AND os.date BETWEEN bs.date - 1 AND bs.date + 1
+	  AND (b.status IN ('confirmed', 'completed') OR (b.status = 'pending' AND bf.pending_holds_seats))
+	  AND NOT ` + bookingCoversSlotSQL + `
+	  AND os.starts_at - make_interval(mins => bf.buffer_before_minutes)
+	      < ` + slotEndsAtSQL + ` + make_interval(mins => bf.buffer_after_minutes)
+	  AND os.ends_at + make_interval(mins => bf.buffer_after_minutes)
+	      > ` + slotStartsAtSQL + ` - make_interval(mins => bf.buffer_before_minutes)`
+
+// bufferFreeSQL — буферы объекта вокруг слота bs не заняты соседними бронированиями
+const bufferFreeSQL = `NOT EXISTS (` + bufferConflictsSQL + `)`
+
+// slotBaseOpenSQL — слот bs открыт по расписанию, закрытиям и часам работы (без учёта буферов и мест)
+const slotBaseOpenSQL = `(bs.is_available = true AND bs.removed_at IS NULL AND ` + notClosedSQL + ` AND ` + withinScheduleSQL +
+	` AND ` + withinLocationHoursSQL + `)`
+
+// slotOpenSQL — слот bs открыт для бронирования (без учёта свободных мест)
+const slotOpenSQL = `(` + slotBaseOpenSQL + ` AND ` + bufferFreeSQL + `)`
+
+// slotCapacitySQL возвращает выражение вместимости нового слота: значение параметра maxArg,
+// если оно задано, иначе вместимость объекта бронирования из параметра itemArg
+func slotCapacitySQL(itemArg, maxArg int) string {
+	return fmt.Sprintf(`COALESCE(NULLIF($%d::int, 0), (SELECT capacity FROM booking_items WHERE id = $%d), 1)`, maxArg, itemArg)
+}
@@ -2,23 +2,45 @@ package handlers
 
 import (
 	"booking-system/models"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/lib/pq"
 )
 
 func ApiGetAvailableDatesHandler(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
-	end := start.AddDate(0, 0, 7)
+	filter, err := parseItemFilter(r)
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	itemCondition, itemArgs := filter.where(3)
 
+	days, err := parseAvailableDays(r)
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// days дней вперёд от сегодняшнего дня в поясе каждого объекта; уже закончившиеся слоты не учитываются
+	args := append([]interface{}{time.Now(), days}, itemArgs...)
 	rows, err := models.DB.Query(`
-		SELECT DISTINCT date 
-		FROM booking_slots 
-		WHERE date BETWEEN $1 AND $2 AND is_available = true 
-		ORDER BY date
-	`, start.Format("2006-01-02"), end.Format("2006-01-02"))
+		SELECT DISTINCT bs.date
+		FROM booking_slots bs
+		JOIN booking_items bi ON bs.item_id = bi.id
+		WHERE bs.ends_at > $1 AND bs.date <= ($1::timestamptz AT TIME ZONE item_time_zone(bs.item_id))::date + $2::int
+		  AND `+slotOpenSQL+`
+		  AND `+remainingSeatsSQL+` > 0
+		  AND `+itemCondition+`
+		ORDER BY bs.date
+	`, args...)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -37,14 +59,28 @@ func ApiGetAvailableDatesHandler(w http.ResponseWriter, r *http.Request) {
 
 func ApiGetAvailableSlotsHandler(w http.ResponseWriter, r *http.Request) {
 	date := r.URL.Query().Get("date")
-	itemID := r.URL.Query().Get("item_id")
+	// include_full=true возвращает и полностью занятые слоты, чтобы на них можно было встать в очередь
+	includeFull := r.URL.Query().Get("include_full") == "true"
 
+	// Объекты выбираются по item_id или по любому фильтру объектов, например узлу иерархии location_id
+	filter, err := parseItemFilter(r)
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	itemCondition, itemArgs := filter.where(3)
+
+	args := append([]interface{}{date, includeFull}, itemArgs...)
 	rows, err := models.DB.Query(`
-		SELECT id, date, start_time, end_time, item_id, is_available
-		FROM booking_slots
-		WHERE date = $1 AND item_id = $2 AND is_available = true
-		ORDER BY start_time
-	`, date, itemID)
+		SELECT bs.id, bs.date, bs.start_time, bs.end_time, bs.item_id, bs.is_available,
+		       bs.max_participants, `+remainingSeatsSQL+`, bs.starts_at, bs.ends_at, item_time_zone(bs.item_id)
+		FROM booking_slots bs
+		JOIN booking_items bi ON bs.item_id = bi.id
+		WHERE bs.date = $1 AND `+slotOpenSQL+`
+		  AND ($2 OR `+remainingSeatsSQL+` > 0)
+		  AND `+itemCondition+`
+		ORDER BY bs.start_time, bi.name
+	`, args...)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -54,13 +90,163 @@ func ApiGetAvailableSlotsHandler(w http.ResponseWriter, r *http.Request) {
 	var slots []models.BookingSlot
 	for rows.Next() {
 		var slot models.BookingSlot
-		rows.Scan(&slot.ID, &slot.Date, &slot.StartTime, &slot.EndTime, &slot.ItemID, &slot.IsAvailable)
+		var startsAt, endsAt time.Time
+		var zone string
+		rows.Scan(&slot.ID, &slot.Date, &slot.StartTime, &slot.EndTime, &slot.ItemID, &slot.IsAvailable,
+			&slot.MaxParticipants, &slot.RemainingSeats, &startsAt, &endsAt, &zone)
+		setSlotInstants(&slot, startsAt, endsAt, zone)
 		slots = append(slots, slot)
 	}
 
 	json.NewEncoder(w).Encode(slots)
 }
 
+// bookingError — ошибка бронирования с HTTP-статусом для ответа клиенту
+type bookingError struct {
+	Status     int
+	Message    string
+	Violations []models.PolicyViolation
+}
+
+func (e *bookingError) Error() string {
+	return e.Message
+}
+
+// respondBookingError отправляет клиенту ошибку бронирования или пересечения слотов в JSON
+func respondBookingError(w http.ResponseWriter, err error) {
+	var oe *slotOverlapError
+	if errors.As(err, &oe) {
+		respondWithJSON(w, http.StatusConflict, map[string]interface{}{"error": oe.Error(), "conflicts": oe.Overlaps})
+		return
+	}
+
+	var be *bookingError
+	if !errors.As(err, &be) {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	payload := map[string]interface{}{"error": be.Message}
+	if len(be.Violations) > 0 {
+		payload["violations"] = be.Violations
+	}
+	respondWithJSON(w, be.Status, payload)
+}
+
+// bookSlot бронирует participants мест в слоте для пользователя в рамках транзакции tx.
+// Слот блокируется FOR UPDATE, поэтому параллельные бронирования одного слота выполняются последовательно.
+// Бронирование объекта, требующего подтверждения, создаётся в статусе 'pending'
+func bookSlot(tx *sql.Tx, userID, slotID string, participants int) (string, error) {
+	return bookSlots(tx, userID, []string{slotID}, participants)
+}
+
+// validateSlotBooking блокирует слот и проверяет, что пользователь может занять в нём participants мест.
+// excludeBookingID (если задан) не учитывается в лимитах — это переносимое бронирование
+func validateSlotBooking(tx *sql.Tx, userID, slotID string, participants int, excludeBookingID string) error {
+	if _, err := checkSlotAvailability(tx, userID, slotID, participants, excludeBookingID); err != nil {
+		return err
+	}
+	return checkPolicyViolations(tx, userID, slotID, excludeBookingID)
+}
+
+// checkSlotAvailability блокирует слот и проверяет, что он открыт, в нём есть participants свободных мест,
+// буферы объекта вокруг него свободны и пользователь ещё не занимает его. Возвращает момент начала слота.
+// excludeBookingID (если задан) не мешает буферам — это переносимое бронирование
+func checkSlotAvailability(tx *sql.Tx, userID, slotID string, participants int, excludeBookingID string) (time.Time, error) {
+	if participants <= 0 {
+		return time.Time{}, &bookingError{Status: http.StatusBadRequest, Message: "Participants must be positive"}
+	}
+
+	var start time.Time
+	var maxParticipants int
+	err := tx.QueryRow(`
+		SELECT starts_at, max_participants
+		FROM booking_slots WHERE id = $1 FOR UPDATE
+	`, slotID).Scan(&start, &maxParticipants)
+	if err == sql.ErrNoRows {
+		return time.Time{}, &bookingError{Status: http.StatusNotFound, Message: "Slot not found"}
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// Бронирования соседних слотов объекта с буферами выполняются последовательно,
+	// иначе параллельные транзакции не увидят друг друга при проверке буферов
+	_, err = tx.Exec(`
+		SELECT 1 FROM booking_items bi JOIN booking_slots bs ON bs.item_id = bi.id
+		WHERE bs.id = $1 AND (bi.buffer_before_minutes > 0 OR bi.buffer_after_minutes > 0)
+		FOR UPDATE OF bi
+	`, slotID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var isOpen, bufferTaken bool
+	var remaining int
+	err = tx.QueryRow(`
+		SELECT `+slotBaseOpenSQL+`, `+remainingSeatsSQL+`,
+		       EXISTS (`+bufferConflictsSQL+` AND b.id::text <> $2)
+		FROM booking_slots bs WHERE bs.id = $1
+	`, slotID, excludeBookingID).Scan(&isOpen, &remaining, &bufferTaken)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !isOpen {
+		return time.Time{}, &bookingError{Status: http.StatusConflict, Message: "Slot is not available"}
+	}
+
+	if bufferTaken {
+		return time.Time{}, &bookingError{
+			Status:  http.StatusConflict,
+			Message: "Slot is too close to another booking: setup or cleanup time is required in between",
+		}
+	}
+
+	if remaining < participants {
+		return time.Time{}, &bookingError{
+			Status:  http.StatusConflict,
+			Message: fmt.Sprintf("Not enough seats: %d of %d left", remaining, maxParticipants),
+		}
+	}
+
+	var alreadyBooked bool
+	err = tx.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM bookings b, booking_slots bs
+			WHERE bs.id = $2 AND b.user_id = $1 AND `+bookingCoversSlotSQL+`
+			  AND b.status NOT IN ('cancelled', 'rejected')
+		)
+	`, userID, slotID).Scan(&alreadyBooked)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if alreadyBooked {
+		return time.Time{}, &bookingError{Status: http.StatusConflict, Message: "Slot is already booked by this user"}
+	}
+
+	return start, nil
+}
+
+// checkPolicyViolations проверяет правила бронирования и возвращает их нарушения как bookingError
+func checkPolicyViolations(tx *sql.Tx, userID, slotID string, excludeBookingID string) error {
+	violations, err := checkBookingPolicy(tx, userID, slotID, excludeBookingID)
+	if err != nil {
+		return err
+	}
+
+	if len(violations) > 0 {
+		return &bookingError{
+			Status:     http.StatusForbidden,
+			Message:    "Booking rules violated",
+			Violations: violations,
+		}
+	}
+
+	return nil
+}
+
 func ApiBookSlotHandler(w http.ResponseWriter, r *http.Request) {
 	session, _ := models.Store.Get(r, "session")
 	userID, ok := session.Values["user_id"].(string)
@@ -72,6 +258,18 @@ func ApiBookSlotHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	slotID := vars["id"]
 
+	// Тело запроса необязательно: по умолчанию бронируется одно место
+	var req struct {
+		Participants int `json:"participants"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Participants == 0 {
+		req.Participants = 1
+	}
+
 	tx, err := models.DB.Begin()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -79,50 +277,137 @@ func ApiBookSlotHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer tx.Rollback()
 
-	var isAvailable bool
-	err = tx.QueryRow("SELECT is_available FROM booking_slots WHERE id = $1 FOR UPDATE", slotID).
-		Scan(&isAvailable)
+	bookingID, err := bookSlot(tx, userID, slotID, req.Participants)
 	if err != nil {
-		http.Error(w, "Slot not found", http.StatusNotFound)
+		respondBookingError(w, err)
 		return
 	}
 
-	if !isAvailable {
-		http.Error(w, "Slot is not available", http.StatusConflict)
+	var status string
+	if err := tx.QueryRow("SELECT status FROM bookings WHERE id = $1", bookingID).Scan(&status); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	var bookingCount int
-	err = tx.QueryRow("SELECT COUNT(*) FROM bookings WHERE user_id = $1", userID).
-		Scan(&bookingCount)
-	if err != nil {
+	if err := tx.Commit(); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if bookingCount >= 3 {
-		http.Error(w, "Booking limit reached", http.StatusForbidden)
-		return
+	respondWithJSON(w, http.StatusCreated, map[string]string{"id": bookingID, "status": status})
+}
+
+// bookingStatuses — допустимые статусы бронирования
+var bookingStatuses = map[string]bool{
+	"pending":   true,
+	"confirmed": true,
+	"rejected":  true,
+	"cancelled": true,
+	"completed": true,
+	"no_show":   true,
+}
+
+// parseStatusFilter разбирает параметр status=a,b,c; пустой параметр означает все статусы
+func parseStatusFilter(r *http.Request) ([]string, error) {
+	value := r.URL.Query().Get("status")
+	if value == "" {
+		return nil, nil
+	}
+	var statuses []string
+	for _, status := range strings.Split(value, ",") {
+		status = strings.TrimSpace(status)
+		if !bookingStatuses[status] {
+			return nil, fmt.Errorf("unknown booking status %q", status)
+		}
+		statuses = append(statuses, status)
 	}
+	return statuses, nil
+}
 
-	_, err = tx.Exec("INSERT INTO bookings (user_id, slot_id) VALUES ($1, $2)", userID, slotID)
+// cancelBooking отменяет подтверждённое или ожидающее подтверждения бронирование в рамках транзакции tx
+// с указанием причины и передаёт освободившиеся места листам ожидания его слотов. Строка бронирования сохраняется для истории.
+// Бронирование из комплекта отменяется вместе с остальными бронированиями комплекта
+func cancelBooking(tx *sql.Tx, bookingID, reason string) error {
+	var bundleBookingID *string
+	err := tx.QueryRow(`
+		UPDATE bookings
+		SET status = 'cancelled', cancelled_at = NOW(), cancel_reason = NULLIF($2, ''), updated_at = NOW()
+		WHERE id = $1 AND status IN ('confirmed', 'pending')
+		RETURNING bundle_booking_id
+	`, bookingID, reason).Scan(&bundleBookingID)
+	if err == sql.ErrNoRows {
+		return &bookingError{Status: http.StatusConflict, Message: "Only active bookings can be cancelled"}
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return err
+	}
+	if err := promoteBookingWaitlists(tx, bookingID); err != nil {
+		return err
+	}
+	if bundleBookingID == nil {
+		return nil
+	}
+	return cancelBundleSiblings(tx, *bundleBookingID, bookingID, reason)
+}
+
+// completePastBookings фиксирует неявки, помечает завершёнными подтверждённые бронирования
+// прошедших слотов и отклоняет заявки, которые не успели подтвердить до начала слота
+func completePastBookings() error {
+	// Неявки фиксируются первыми, чтобы бронирования без отметки о приходе не стали завершёнными
+	if err := markNoShows(); err != nil {
+		return err
 	}
 
-	_, err = tx.Exec("UPDATE booking_slots SET is_available = false WHERE id = $1", slotID)
+	_, err := models.DB.Exec(`
+		UPDATE bookings b SET status = 'completed', updated_at = NOW()
+		FROM booking_slots bs
+		WHERE b.slot_id = bs.id AND b.status = 'confirmed' AND ` + bookingEndsAtSQL + ` <= NOW()
+	`)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return err
 	}
 
-	if err := tx.Commit(); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	tx, err := models.DB.Begin()
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	w.WriteHeader(http.StatusCreated)
+	rows, err := tx.Query(`
+		UPDATE bookings b
+		SET status = 'rejected', reject_reason = 'Not approved before the slot started', updated_at = NOW()
+		FROM booking_slots bs
+		WHERE b.slot_id = bs.id AND b.status = 'pending' AND ` + slotStartsAtSQL + ` <= NOW()
+		RETURNING b.id, b.bundle_booking_id
+	`)
+	if err != nil {
+		return err
+	}
+	rejected := make(map[string]string)
+	for rows.Next() {
+		var id string
+		var bundleBookingID *string
+		if err := rows.Scan(&id, &bundleBookingID); err != nil {
+			rows.Close()
+			return err
+		}
+		if bundleBookingID != nil {
+			rejected[*bundleBookingID] = id
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Остальные бронирования комплекта с отклонённой заявкой отменяются
+	for bundleBookingID, bookingID := range rejected {
+		if err := cancelBundleSiblings(tx, bundleBookingID, bookingID, "Bundle booking rejected"); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
 func ApiCancelBookingHandler(w http.ResponseWriter, r *http.Request) {
@@ -136,6 +421,17 @@ func ApiCancelBookingHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	bookingID := vars["id"]
 
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Reason == "" {
+		req.Reason = "Cancelled by user"
+	}
+
 	tx, err := models.DB.Begin()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -143,25 +439,20 @@ func ApiCancelBookingHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer tx.Rollback()
 
-	var slotID string
+	var lockedID string
 	err = tx.QueryRow(`
-		SELECT slot_id FROM bookings 
+		SELECT id FROM bookings 
 		WHERE id = $1 AND user_id = $2
-	`, bookingID, userID).Scan(&slotID)
+		FOR UPDATE
+	`, bookingID, userID).Scan(&lockedID)
 	if err != nil {
 		http.Error(w, "Booking not found", http.StatusNotFound)
 		return
 	}
 
-	_, err = tx.Exec("DELETE FROM bookings WHERE id = $1", bookingID)
+	penalty, err := cancelBookingByUser(tx, bookingID, req.Reason)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	_, err = tx.Exec("UPDATE booking_slots SET is_available = true WHERE id = $1", slotID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondBookingError(w, err)
 		return
 	}
 
@@ -170,7 +461,7 @@ func ApiCancelBookingHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	respondWithJSON(w, http.StatusOK, map[string]bool{"penalty": penalty})
 }
 
 func ApiGetUserBookingsHandler(w http.ResponseWriter, r *http.Request) {
@@ -181,38 +472,104 @@ func ApiGetUserBookingsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	statuses, err := parseStatusFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	rows, err := models.DB.Query(`
-		SELECT b.id, b.created_at, bs.date, bs.start_time, bs.end_time, bi.name 
+		SELECT b.id, b.created_at, bs.date, bs.start_time, `+bookingEndTimeSQL+`, bi.name, b.participants,
+		       b.status, b.cancelled_at, b.cancel_reason, b.reject_reason, b.checked_in_at, b.series_id,
+		       bs.starts_at, `+bookingEndsAtSQL+`, bi.free_cancel_hours, bi.late_cancel_action
 		FROM bookings b
 		JOIN booking_slots bs ON b.slot_id = bs.id
 		JOIN booking_items bi ON bs.item_id = bi.id
-		WHERE b.user_id = $1
+		WHERE b.user_id = $1 AND ($2::text[] IS NULL OR b.status = ANY($2))
 		ORDER BY bs.date, bs.start_time
-	`, userID)
+	`, userID, pq.Array(statuses))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	defer rows.Close()
 
+	// Моменты времени отдаются в RFC 3339 в поясе пользователя; date и время слота — «настенные» в поясе объекта
+	loc := userLocation(models.DB, userID)
+	now := time.Now()
 	var bookings []map[string]interface{}
 	for rows.Next() {
 		var b struct {
-			ID        string
-			CreatedAt string
-			Date      string
-			StartTime string
-			EndTime   string
-			ItemName  string
+			ID           string
+			CreatedAt    string
+			Date         string
+			StartTime    string
+			EndTime      string
+			ItemName     string
+			Participants int
+			Status       string
+			CancelledAt  *string
+			CancelReason *string
+			RejectReason *string
+			CheckedInAt  *string
+			SeriesID     *string
+			StartsAt     time.Time
+			EndsAt       time.Time
+			FreeCancel   *int
+			LateAction   *string
 		}
-		rows.Scan(&b.ID, &b.CreatedAt, &b.Date, &b.StartTime, &b.EndTime, &b.ItemName)
+		rows.Scan(&b.ID, &b.CreatedAt, &b.Date, &b.StartTime, &b.EndTime, &b.ItemName, &b.Participants,
+			&b.Status, &b.CancelledAt, &b.CancelReason, &b.RejectReason, &b.CheckedInAt, &b.SeriesID,
+			&b.StartsAt, &b.EndsAt, &b.FreeCancel, &b.LateAction)
+
+		cancellation := checkCancellation(itemCancellationPolicy(b.FreeCancel, b.LateAction), b.Status, b.StartsAt, now)
+
+		bookings = append(bookings, map[string]interface{}{
+			"type":          "booking",
+			"id":            b.ID,
+			"created_at":    b.CreatedAt,
+			"date":          b.Date,
+			"start_time":    b.StartTime,
+			"end_time":      b.EndTime,
+			"starts_at":     b.StartsAt.In(loc).Format(time.RFC3339),
+			"ends_at":       b.EndsAt.In(loc).Format(time.RFC3339),
+			"item_name":     b.ItemName,
+			"participants":  b.Participants,
+			"status":        b.Status,
+			"cancelled_at":  b.CancelledAt,
+			"cancel_reason": b.CancelReason,
+			"reject_reason": b.RejectReason,
+			"checked_in_at": b.CheckedInAt,
+			"series_id":     b.SeriesID,
+			"cancellable":   cancellation.Cancellable,
+			"late_cancel":   cancellation.Late,
+			"cancel_until":  cancellation.Deadline.In(loc).Format(time.RFC3339),
+		})
+	}
+
+	// Записи в листах ожидания возвращаются вместе с бронированиями, если не задан фильтр по статусу
+	var waitlist []models.WaitlistEntry
+	if statuses == nil {
+		waitlist, err = loadUserWaitlist(userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	for _, e := range waitlist {
 		bookings = append(bookings, map[string]interface{}{
-			"id":         b.ID,
-			"created_at": b.CreatedAt,
-			"date":       b.Date,
-			"start_time": b.StartTime,
-			"end_time":   b.EndTime,
-			"item_name":  b.ItemName,
+			"type":             "waitlist",
+			"id":               e.ID,
+			"created_at":       e.CreatedAt,
+			"date":             e.Date,
+			"start_time":       e.StartTime,
+			"end_time":         e.EndTime,
+			"item_name":        e.ItemName,
+			"participants":     e.Participants,
+			"slot_id":          e.SlotID,
+			"status":           e.Status,
+			"position":         e.Position,
+			"offer_expires_at": e.OfferExpiresAt,
 		})
 	}
 
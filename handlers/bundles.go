@@ -0,0 +1,670 @@
+package handlers
+
+import (
+	"booking-system/models"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// bundleMemberSeats возвращает, сколько мест элемент комплекта занимает при бронировании на participants человек
+func bundleMemberSeats(member models.BundleMember, participants int) int {
+	if member.Quantity != nil {
+		return *member.Quantity
+	}
+	return participants
+}
+
+// loadBundleMembers загружает элементы комплектов bundleIDs, упорядоченные по ID объекта
+func loadBundleMembers(q queryer, bundleIDs []string) (map[uuid.UUID][]models.BundleMember, error) {
+	rows, err := q.Query(`
+		SELECT m.bundle_id, m.item_id, bi.name, m.quantity
+		FROM item_bundle_members m
+		JOIN booking_items bi ON m.item_id = bi.id
+		WHERE m.bundle_id = ANY($1::uuid[])
+		ORDER BY m.bundle_id, m.item_id
+	`, pq.Array(bundleIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make(map[uuid.UUID][]models.BundleMember)
+	for rows.Next() {
+		var bundleID uuid.UUID
+		var m models.BundleMember
+		var quantity sql.NullInt64
+		if err := rows.Scan(&bundleID, &m.ItemID, &m.ItemName, &quantity); err != nil {
+			return nil, err
+		}
+		if quantity.Valid {
+			n := int(quantity.Int64)
+			m.Quantity = &n
+		}
+		members[bundleID] = append(members[bundleID], m)
+	}
+	return members, rows.Err()
+}
+
+// listBundles возвращает комплекты с их элементами; пустой bundleID — все комплекты
+func listBundles(q queryer, bundleID string) ([]models.ItemBundle, error) {
+	rows, err := q.Query(`
+		SELECT id, name, COALESCE(description, '')
+		FROM item_bundles
+		WHERE $1 = '' OR id = NULLIF($1, '')::uuid
+		ORDER BY name
+	`, bundleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bundles := []models.ItemBundle{}
+	var ids []string
+	for rows.Next() {
+		var b models.ItemBundle
+		if err := rows.Scan(&b.ID, &b.Name, &b.Description); err != nil {
+			return nil, err
+		}
+		bundles = append(bundles, b)
+		ids = append(ids, b.ID.String())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	members, err := loadBundleMembers(q, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range bundles {
+		bundles[i].Members = members[bundles[i].ID]
+		if bundles[i].Members == nil {
+			bundles[i].Members = []models.BundleMember{}
+		}
+	}
+	return bundles, nil
+}
+
+// loadBundle возвращает комплект по ID или bookingError 404
+func loadBundle(q queryer, bundleID string) (models.ItemBundle, error) {
+	if _, err := uuid.Parse(bundleID); err != nil {
+		return models.ItemBundle{}, &bookingError{Status: http.StatusNotFound, Message: "Bundle not found"}
+	}
+	bundles, err := listBundles(q, bundleID)
+	if err != nil {
+		return models.ItemBundle{}, err
+	}
+	if len(bundles) == 0 {
+		return models.ItemBundle{}, &bookingError{Status: http.StatusNotFound, Message: "Bundle not found"}
+	}
+	return bundles[0], nil
+}
+
+// bundleInput — тело запроса создания и изменения комплекта
+type bundleInput struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Members     []struct {
+		ItemID   uuid.UUID `json:"item_id"`
+		Quantity *int      `json:"quantity"`
+	} `json:"members"`
+}
+
+func (in *bundleInput) validate() error {
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		return fmt.Errorf("Name is required")
+	}
+	if len(in.Members) < 2 {
+		return fmt.Errorf("A bundle needs at least two items")
+	}
+	seen := make(map[uuid.UUID]bool)
+	for _, m := range in.Members {
+		if seen[m.ItemID] {
+			return fmt.Errorf("Item %s is listed twice", m.ItemID)
+		}
+		seen[m.ItemID] = true
+		if m.Quantity != nil && *m.Quantity <= 0 {
+			return fmt.Errorf("Quantity must be positive")
+		}
+	}
+	return nil
+}
+
+// saveBundle создаёт комплект (пустой bundleID) или заменяет его название и состав
+func saveBundle(tx *sql.Tx, bundleID string, in bundleInput) (string, error) {
+	var err error
+	if bundleID == "" {
+		err = tx.QueryRow(`
+			INSERT INTO item_bundles (name, description) VALUES ($1, NULLIF($2, '')) RETURNING id
+		`, in.Name, in.Description).Scan(&bundleID)
+	} else {
+		err = tx.QueryRow(`
+			UPDATE item_bundles SET name = $2, description = NULLIF($3, ''), updated_at = NOW()
+			WHERE id = $1
+			RETURNING id
+		`, bundleID, in.Name, in.Description).Scan(&bundleID)
+	}
+	if err == sql.ErrNoRows {
+		return "", &bookingError{Status: http.StatusNotFound, Message: "Bundle not found"}
+	}
+	if isUniqueViolation(err) {
+		return "", &bookingError{Status: http.StatusConflict, Message: "Bundle with this name already exists"}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := tx.Exec("DELETE FROM item_bundle_members WHERE bundle_id = $1", bundleID); err != nil {
+		return "", err
+	}
+	for _, m := range in.Members {
+		_, err := tx.Exec(`
+			INSERT INTO item_bundle_members (bundle_id, item_id, quantity) VALUES ($1, $2, $3)
+		`, bundleID, m.ItemID, m.Quantity)
+		if isForeignKeyViolation(err) {
+			return "", &bookingError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Item %s not found", m.ItemID)}
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return bundleID, nil
+}
+
+// cancelBundleSiblings отменяет остальные активные бронирования комплекта, записывая отмену в их историю,
+// и передаёт их места листам ожидания
+func cancelBundleSiblings(tx *sql.Tx, bundleBookingID, bookingID, reason string) error {
+	rows, err := tx.Query(`
+		UPDATE bookings
+		SET status = 'cancelled', cancelled_at = NOW(), cancel_reason = NULLIF($3, ''), updated_at = NOW()
+		WHERE bundle_booking_id = $1 AND id <> $2 AND status IN ('confirmed', 'pending')
+		RETURNING id
+	`, bundleBookingID, bookingID, reason)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := logBookingEvent(tx, id, "cancelled_with_bundle", nil, nil, ""); err != nil {
+			return err
+		}
+		if err := promoteBookingWaitlists(tx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bundleWindowKey — интервал, который можно забронировать в одном объекте комплекта
+type bundleWindowKey struct {
+	Date       string
+	Start, End int
+}
+
+// memberRanges возвращает все интервалы из подряд идущих слотов объекта длительностью не меньше duration минут,
+// в каждом слоте которых есть seats свободных мест
+func memberRanges(slots []searchSlot, duration, seats int) map[bundleWindowKey]bool {
+	var free []searchSlot
+	for _, s := range slots {
+		if s.Remaining >= seats {
+			free = append(free, s)
+		}
+	}
+
+	ranges := make(map[bundleWindowKey]bool)
+	for i := range free {
+		end := free[i].End
+		for j := i; j < len(free); j++ {
+			if j > i {
+				if free[j].Date != free[i].Date || (free[j].Start != end && free[j].Start != end+free[j].Gap) {
+					break
+				}
+				end = free[j].End
+			}
+			if end-free[i].Start >= duration {
+				ranges[bundleWindowKey{Date: free[i].Date, Start: free[i].Start, End: end}] = true
+			}
+		}
+	}
+	return ranges
+}
+
+// convertWindowKey переводит интервал из пояса from в пояс to. ok=false, если в поясе to
+// интервал не укладывается в один день
+func convertWindowKey(key bundleWindowKey, from, to *time.Location) (bundleWindowKey, bool) {
+	if from.String() == to.String() {
+		return key, true
+	}
+	day, err := time.ParseInLocation("2006-01-02", key.Date, from)
+	if err != nil {
+		return key, false
+	}
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, key.Start, 0, 0, from).In(to)
+	end := time.Date(day.Year(), day.Month(), day.Day(), 0, key.End, 0, 0, from).In(to)
+	if end.Format("2006-01-02") != start.Format("2006-01-02") {
+		return key, false
+	}
+	return bundleWindowKey{
+		Date:  start.Format("2006-01-02"),
+		Start: start.Hour()*60 + start.Minute(),
+		End:   end.Hour()*60 + end.Minute(),
+	}, true
+}
+
+// bundleMemberZones возвращает часовые пояса объектов комплекта в порядке его состава
+func bundleMemberZones(q queryer, bundle models.ItemBundle) ([]*time.Location, error) {
+	zones := make([]*time.Location, len(bundle.Members))
+	for i, m := range bundle.Members {
+		loc, err := itemLocation(q, m.ItemID.String())
+		if err != nil {
+			return nil, err
+		}
+		zones[i] = loc
+	}
+	return zones, nil
+}
+
+func ApiGetBundlesHandler(w http.ResponseWriter, r *http.Request) {
+	bundles, err := listBundles(models.DB, "")
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	respondWithJSON(w, http.StatusOK, bundles)
+}
+
+func ApiCreateBundleHandler(w http.ResponseWriter, r *http.Request) {
+	saveBundleRequest(w, r, "")
+}
+
+func ApiUpdateBundleHandler(w http.ResponseWriter, r *http.Request) {
+	bundleID := mux.Vars(r)["id"]
+	if _, err := uuid.Parse(bundleID); err != nil {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Bundle not found"})
+		return
+	}
+	saveBundleRequest(w, r, bundleID)
+}
+
+// saveBundleRequest обрабатывает создание (пустой bundleID) и замену комплекта администратором
+func saveBundleRequest(w http.ResponseWriter, r *http.Request, bundleID string) {
+	session, _ := models.Store.Get(r, "session")
+	role, ok := session.Values["role"].(string)
+	if !ok || role != "admin" {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	var in bundleInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	if err := in.validate(); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	status := http.StatusOK
+	if bundleID == "" {
+		status = http.StatusCreated
+	}
+	bundleID, err = saveBundle(tx, bundleID, in)
+	if err != nil {
+		respondBookingError(w, err)
+		return
+	}
+
+	bundle, err := loadBundle(tx, bundleID)
+	if err != nil {
+		respondBookingError(w, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, status, bundle)
+}
+
+func ApiDeleteBundleHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	role, ok := session.Values["role"].(string)
+	if !ok || role != "admin" {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	bundleID := mux.Vars(r)["id"]
+	if _, err := uuid.Parse(bundleID); err != nil {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Bundle not found"})
+		return
+	}
+
+	// Уже сделанные бронирования комплекта остаются, теряется только ссылка на сам комплект
+	res, err := models.DB.Exec("DELETE FROM item_bundles WHERE id = $1", bundleID)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Bundle not found"})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// ApiGetBundleAvailabilityHandler ищет интервалы, свободные одновременно во всех объектах комплекта.
+// Для каждого начала возвращается самый короткий общий интервал
+func ApiGetBundleAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	bundle, err := loadBundle(models.DB, mux.Vars(r)["id"])
+	if err != nil {
+		respondBookingError(w, err)
+		return
+	}
+
+	from, err := parseLocalDateTime(query.Get("from"), false)
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid from"})
+		return
+	}
+	to, err := parseLocalDateTime(query.Get("to"), true)
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid to"})
+		return
+	}
+	if !to.After(from) {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "to must be after from"})
+		return
+	}
+	if to.Sub(from) > maxSearchDays*24*time.Hour {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("Search range cannot exceed %d days", maxSearchDays),
+		})
+		return
+	}
+
+	duration, participants, err := parseWindowParams(query, 1)
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	windows := []models.BundleWindow{}
+	if len(bundle.Members) == 0 {
+		respondWithJSON(w, http.StatusOK, windows)
+		return
+	}
+	zones, err := bundleMemberZones(models.DB, bundle)
+	if err != nil {
+		respondBookingError(w, err)
+		return
+	}
+
+	itemIDs := make([]string, len(bundle.Members))
+	for i, m := range bundle.Members {
+		itemIDs[i] = m.ItemID.String()
+	}
+	itemSlots, err := loadFreeSlots(models.DB, from, to, 1, "bi.id = ANY($4::uuid[])", []interface{}{pq.Array(itemIDs)})
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	slotsByItem := make(map[uuid.UUID][]searchSlot)
+	for _, slots := range itemSlots {
+		slotsByItem[slots[0].ItemID] = slots
+	}
+
+	// Пересечение интервалов всех элементов комплекта. Объекты могут быть в разных поясах,
+	// поэтому интервалы сравниваются в поясе первого объекта
+	var common map[bundleWindowKey]bool
+	for i, m := range bundle.Members {
+		ranges := make(map[bundleWindowKey]bool)
+		for key := range memberRanges(slotsByItem[m.ItemID], duration, bundleMemberSeats(m, participants)) {
+			if key, ok := convertWindowKey(key, zones[i], zones[0]); ok {
+				ranges[key] = true
+			}
+		}
+		if common == nil {
+			common = ranges
+			continue
+		}
+		for key := range common {
+			if !ranges[key] {
+				delete(common, key)
+			}
+		}
+	}
+
+	shortest := make(map[bundleWindowKey]bundleWindowKey)
+	for key := range common {
+		start := bundleWindowKey{Date: key.Date, Start: key.Start}
+		if best, ok := shortest[start]; !ok || key.End < best.End {
+			shortest[start] = key
+		}
+	}
+	keys := make([]bundleWindowKey, 0, len(shortest))
+	for _, key := range shortest {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Date != keys[j].Date {
+			return keys[i].Date < keys[j].Date
+		}
+		return keys[i].Start < keys[j].Start
+	})
+	if len(keys) > maxWindowsPerItem {
+		keys = keys[:maxWindowsPerItem]
+	}
+
+	for _, key := range keys {
+		windows = append(windows, models.BundleWindow{
+			Date:      key.Date,
+			StartTime: formatClock(key.Start),
+			EndTime:   formatClock(key.End),
+			TimeZone:  zones[0].String(),
+		})
+	}
+	respondWithJSON(w, http.StatusOK, windows)
+}
+
+// ApiBookBundleHandler бронирует все объекты комплекта на один интервал (в поясе первого объекта). Если хотя бы один объект
+// недоступен, не создаётся ни одного бронирования. Правила бронирования проверяются один раз для всего комплекта
+func ApiBookBundleHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	userID, ok := session.Values["user_id"].(string)
+	if !ok {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		Date         string `json:"date"`
+		StartTime    string `json:"start_time"`
+		EndTime      string `json:"end_time"`
+		Participants int    `json:"participants"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	if req.Participants == 0 {
+		req.Participants = 1
+	}
+	if req.Participants < 0 {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid participants"})
+		return
+	}
+	if _, err := time.ParseInLocation("2006-01-02", req.Date, time.Local); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid date"})
+		return
+	}
+
+	var window clockRange
+	var err error
+	if window.Start, err = parseClock(req.StartTime); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid start_time"})
+		return
+	}
+	if window.End, err = parseClock(req.EndTime); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid end_time"})
+		return
+	}
+	if window.End <= window.Start {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "end_time must be after start_time"})
+		return
+	}
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	bundle, err := loadBundle(tx, mux.Vars(r)["id"])
+	if err != nil {
+		respondBookingError(w, err)
+		return
+	}
+	if len(bundle.Members) == 0 {
+		respondWithJSON(w, http.StatusConflict, map[string]string{"error": "Bundle has no items"})
+		return
+	}
+
+	// Интервал задан в поясе первого объекта комплекта и бронируется в тот же момент во всех объектах
+	zones, err := bundleMemberZones(tx, bundle)
+	if err != nil {
+		respondBookingError(w, err)
+		return
+	}
+	requested := bundleWindowKey{Date: req.Date, Start: window.Start, End: window.End}
+
+	// Объекты обрабатываются в порядке ID, чтобы параллельные бронирования комплектов блокировали слоты в одном порядке
+	memberSlots := make([][]string, len(bundle.Members))
+	var start time.Time
+	var firstSlotID string
+	for i, m := range bundle.Members {
+		local, ok := convertWindowKey(requested, zones[0], zones[i])
+		if !ok {
+			respondBookingError(w, prefixBundleError(&bookingError{
+				Status:  http.StatusConflict,
+				Message: "Requested range spans two days in the item's time zone",
+			}, m))
+			return
+		}
+		slotIDs, err := findRangeSlots(tx, m.ItemID.String(), local.Date, clockRange{Start: local.Start, End: local.End})
+		if err != nil {
+			respondBookingError(w, prefixBundleError(err, m))
+			return
+		}
+		memberStart, err := lockAndCheckSlots(tx, userID, slotIDs, bundleMemberSeats(m, req.Participants))
+		if err != nil {
+			respondBookingError(w, prefixBundleError(err, m))
+			return
+		}
+		if i == 0 || memberStart.Before(start) {
+			start, firstSlotID = memberStart, slotIDs[0]
+		}
+		memberSlots[i] = slotIDs
+	}
+
+	if err := checkPolicyViolations(tx, userID, firstSlotID, ""); err != nil {
+		respondBookingError(w, err)
+		return
+	}
+
+	var bundleBookingID string
+	err = tx.QueryRow(`
+		INSERT INTO bundle_bookings (bundle_id, user_id) VALUES ($1, $2) RETURNING id
+	`, bundle.ID, userID).Scan(&bundleBookingID)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	bookings := make([]map[string]interface{}, 0, len(bundle.Members))
+	for i, m := range bundle.Members {
+		bookingID, err := insertBooking(tx, userID, memberSlots[i], bundleMemberSeats(m, req.Participants))
+		if err != nil {
+			respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		var status string
+		err = tx.QueryRow(`
+			UPDATE bookings SET bundle_booking_id = $2 WHERE id = $1 RETURNING status
+		`, bookingID, bundleBookingID).Scan(&status)
+		if err != nil {
+			respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		bookings = append(bookings, map[string]interface{}{
+			"id":       bookingID,
+			"item_id":  m.ItemID,
+			"status":   status,
+			"slot_ids": memberSlots[i],
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"id":        bundleBookingID,
+		"bundle_id": bundle.ID,
+		"bookings":  bookings,
+	})
+}
+
+// prefixBundleError добавляет к бизнес-ошибке название объекта комплекта, из-за которого не удалось бронирование
+func prefixBundleError(err error, member models.BundleMember) error {
+	var be *bookingError
+	if errors.As(err, &be) {
+		return &bookingError{
+			Status:     be.Status,
+			Message:    member.ItemName + ": " + be.Message,
+			Violations: be.Violations,
+		}
+	}
+	return err
+}
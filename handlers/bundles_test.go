@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemberRanges(t *testing.T) {
+	slots := []searchSlot{
+		{SlotID: "a", Date: "2024-05-06", Start: 9 * 60, End: 10 * 60, Remaining: 4},
+		{SlotID: "b", Date: "2024-05-06", Start: 10 * 60, End: 11 * 60, Remaining: 1},
+		{SlotID: "c", Date: "2024-05-06", Start: 11 * 60, End: 12 * 60, Remaining: 4},
+		// Разрыв в час: интервал не продолжается
+		{SlotID: "d", Date: "2024-05-06", Start: 13 * 60, End: 14 * 60, Remaining: 4},
+		// Промежуток, равный буферам объекта, разрывом не считается
+		{SlotID: "e", Date: "2024-05-06", Start: 14*60 + 15, End: 15*60 + 15, Remaining: 4, Gap: 15},
+		// Следующий день не склеивается с предыдущим
+		{SlotID: "f", Date: "2024-05-07", Start: 0, End: 60, Remaining: 4},
+	}
+
+	tests := []struct {
+		name     string
+		duration int
+		seats    int
+		want     []bundleWindowKey
+	}{
+		{
+			name:     "single slots and all longer ranges",
+			duration: 60,
+			seats:    1,
+			want: []bundleWindowKey{
+				{"2024-05-06", 9 * 60, 10 * 60}, {"2024-05-06", 9 * 60, 11 * 60}, {"2024-05-06", 9 * 60, 12 * 60},
+				{"2024-05-06", 10 * 60, 11 * 60}, {"2024-05-06", 10 * 60, 12 * 60},
+				{"2024-05-06", 11 * 60, 12 * 60},
+				{"2024-05-06", 13 * 60, 14 * 60}, {"2024-05-06", 13 * 60, 15*60 + 15},
+				{"2024-05-06", 14*60 + 15, 15*60 + 15},
+				{"2024-05-07", 0, 60},
+			},
+		},
+		{
+			name:     "slots without enough seats break ranges",
+			duration: 120,
+			seats:    2,
+			want: []bundleWindowKey{
+				{"2024-05-06", 13 * 60, 15*60 + 15},
+			},
+		},
+		{
+			name:     "too long",
+			duration: 240,
+			seats:    1,
+			want:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := memberRanges(slots, tt.duration, tt.seats)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d ranges %v, want %d %v", len(got), got, len(tt.want), tt.want)
+			}
+			for _, key := range tt.want {
+				if !got[key] {
+					t.Errorf("missing range %+v", key)
+				}
+			}
+		})
+	}
+}
+
+func TestConvertWindowKey(t *testing.T) {
+	moscow := mustLoadLocation(t, "Europe/Moscow")
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+
+	tests := []struct {
+		name     string
+		key      bundleWindowKey
+		from, to *time.Location
+		want     bundleWindowKey
+		ok       bool
+	}{
+		{
+			name: "same zone is unchanged",
+			key:  bundleWindowKey{"2024-01-15", 10 * 60, 11 * 60},
+			from: moscow, to: moscow,
+			want: bundleWindowKey{"2024-01-15", 10 * 60, 11 * 60},
+			ok:   true,
+		},
+		{
+			name: "winter offset",
+			key:  bundleWindowKey{"2024-01-15", 10 * 60, 11 * 60},
+			from: moscow, to: berlin,
+			want: bundleWindowKey{"2024-01-15", 8 * 60, 9 * 60},
+			ok:   true,
+		},
+		{
+			name: "summer offset",
+			key:  bundleWindowKey{"2024-07-15", 10 * 60, 11 * 60},
+			from: moscow, to: berlin,
+			want: bundleWindowKey{"2024-07-15", 9 * 60, 10 * 60},
+			ok:   true,
+		},
+		{
+			name: "moves to the previous day",
+			key:  bundleWindowKey{"2024-01-15", 60, 90},
+			from: moscow, to: berlin,
+			want: bundleWindowKey{"2024-01-14", 23 * 60, 23*60 + 30},
+			ok:   true,
+		},
+		{
+			name: "spans midnight in the target zone",
+			key:  bundleWindowKey{"2024-01-15", 90, 150},
+			from: moscow, to: berlin,
+			ok: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := convertWindowKey(tt.key, tt.from, tt.to)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v (got %+v)", ok, tt.ok, got)
+			}
+			if ok && got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
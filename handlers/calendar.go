@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"booking-system/models"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultAvailableDays — на сколько дней вперёд по умолчанию ищутся даты со свободными слотами
+const defaultAvailableDays = 7
+
+// availabilityHorizon возвращает последний день, доступный для бронирования, по booking_window_days
+func availabilityHorizon(today time.Time) time.Time {
+	return today.AddDate(0, 0, models.BookingWindowDays)
+}
+
+// parseCalendarRange читает интервал дней from/to ("2006-01-02"), по умолчанию — от сегодняшнего дня
+// до горизонта бронирования. Интервал не может начинаться раньше сегодняшнего дня и выходить за горизонт booking_window_days
+func parseCalendarRange(r *http.Request, today time.Time) (time.Time, time.Time, error) {
+	query := r.URL.Query()
+	horizon := availabilityHorizon(today)
+
+	from, to := today, horizon
+	var err error
+	if v := query.Get("from"); v != "" {
+		if from, err = time.ParseInLocation("2006-01-02", v, today.Location()); err != nil {
+			return from, to, fmt.Errorf("invalid from %q", v)
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if to, err = time.ParseInLocation("2006-01-02", v, today.Location()); err != nil {
+			return from, to, fmt.Errorf("invalid to %q", v)
+		}
+	}
+	if from.Before(today) {
+		return from, to, fmt.Errorf("from cannot be earlier than today (%s)", today.Format("2006-01-02"))
+	}
+	if from.After(horizon) {
+		return from, to, fmt.Errorf("from is beyond the booking window: it cannot be later than %s (%d days)",
+			horizon.Format("2006-01-02"), models.BookingWindowDays)
+	}
+	if to.Before(from) {
+		return from, to, fmt.Errorf("to must not be before from")
+	}
+	if to.After(horizon) {
+		return from, to, fmt.Errorf("to cannot be later than %s (booking window is %d days)",
+			horizon.Format("2006-01-02"), models.BookingWindowDays)
+	}
+	if to.Sub(from) > time.Duration(models.BookingWindowDays)*24*time.Hour {
+		return from, to, fmt.Errorf("range cannot exceed %d days", models.BookingWindowDays)
+	}
+	return from, to, nil
+}
+
+// startOfToday возвращает начало сегодняшнего дня в поясе loc
+func startOfToday(loc *time.Location) time.Time {
+	y, m, d := time.Now().In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// loadCalendar возвращает сводку доступности объектов, подходящих под itemCondition, по дням [from, to].
+// Свободным считается ещё не закончившийся открытый слот хотя бы с одним местом; день закрыт,
+// если ни один его слот не открыт по расписанию и закрытиям, а для дня без слотов — если он целиком
+// попадает под общее закрытие. Границы такого дня берутся в поясе календаря loc. Параметры itemCondition нумеруются с $4
+func loadCalendar(q queryer, from, to time.Time, loc *time.Location, itemCondition string, itemArgs []interface{}) ([]models.DayAvailability, error) {
+	// Пояс процесса PostgreSQL не знает: для него берётся пояс сервера БД
+	zone := loc.String()
+	if loc == time.Local {
+		zone = ""
+	}
+	args := append([]interface{}{from.Format("2006-01-02"), to.Format("2006-01-02"), zone}, itemArgs...)
+	rows, err := q.Query(`
+		WITH slots AS (
+			SELECT bs.date,
+			       `+slotBaseOpenSQL+` AS base_open,
+			       bs.ends_at > NOW() AND `+slotOpenSQL+` AND `+remainingSeatsSQL+` > 0 AS free,
+			       `+remainingSeatsSQL+` AS remaining
+			FROM booking_slots bs
+			JOIN booking_items bi ON bs.item_id = bi.id
+			WHERE bs.date BETWEEN $1::date AND $2::date AND bs.removed_at IS NULL
+			  AND `+itemCondition+`
+		)
+		SELECT to_char(d, 'YYYY-MM-DD'),
+		       COUNT(s.date),
+		       COUNT(*) FILTER (WHERE s.free),
+		       COALESCE(SUM(s.remaining) FILTER (WHERE s.free), 0),
+		       CASE WHEN COUNT(s.date) > 0 THEN NOT COALESCE(bool_or(s.base_open), false)
+		            ELSE EXISTS (
+		                SELECT 1 FROM closures c
+		                WHERE c.item_id IS NULL
+		                  AND c.starts_at <= d AT TIME ZONE COALESCE(NULLIF($3, ''), current_setting('TimeZone'))
+		                  AND c.ends_at >= (d + interval '1 day') AT TIME ZONE COALESCE(NULLIF($3, ''), current_setting('TimeZone'))
+		            )
+		       END
+		FROM generate_series($1::timestamp, $2::timestamp, interval '1 day') d
+		LEFT JOIN slots s ON s.date = d::date
+		GROUP BY d
+		ORDER BY d
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []models.DayAvailability{}
+	for rows.Next() {
+		var day models.DayAvailability
+		if err := rows.Scan(&day.Date, &day.TotalSlots, &day.FreeSlots, &day.RemainingSeats, &day.Closed); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
+
+// ApiGetCalendarHandler возвращает сводку доступности по дням для месячного календаря.
+// Объекты выбираются любым фильтром объектов (item_id, location_id, category, ...).
+// Сегодняшний день и горизонт считаются в поясе объекта, если выбран один объект, иначе — в поясе пользователя
+func ApiGetCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseItemFilter(r)
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	itemCondition, itemArgs := filter.where(4)
+
+	session, _ := models.Store.Get(r, "session")
+	loc := models.DBLocation
+	if len(filter.IDs) == 1 {
+		if loc, err = itemLocation(models.DB, filter.IDs[0]); err != nil {
+			respondBookingError(w, err)
+			return
+		}
+	} else if userID, ok := session.Values["user_id"].(string); ok {
+		loc = userLocation(models.DB, userID)
+	}
+
+	from, to, err := parseCalendarRange(r, startOfToday(loc))
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	days, err := loadCalendar(models.DB, from, to, loc, itemCondition, itemArgs)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"time_zone": loc.String(),
+		"from":      from.Format("2006-01-02"),
+		"to":        to.Format("2006-01-02"),
+		"days":      days,
+	})
+}
+
+// ApiGetItemWeekHandler возвращает сводку доступности одного объекта за неделю (с понедельника),
+// в которую попадает date (по умолчанию — сегодня в поясе объекта). Прошедшие дни недели остаются в сводке
+// без свободных слотов, дни за горизонтом бронирования отбрасываются
+func ApiGetItemWeekHandler(w http.ResponseWriter, r *http.Request) {
+	itemID := mux.Vars(r)["id"]
+	loc, err := itemLocation(models.DB, itemID)
+	if err != nil {
+		respondBookingError(w, err)
+		return
+	}
+
+	day := startOfToday(loc)
+	if v := r.URL.Query().Get("date"); v != "" {
+		if day, err = time.ParseInLocation("2006-01-02", v, loc); err != nil {
+			respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid date"})
+			return
+		}
+	}
+	from := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	to := from.AddDate(0, 0, 6)
+	if horizon := availabilityHorizon(startOfToday(loc)); to.After(horizon) {
+		to = horizon
+	}
+	if to.Before(from) {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "The week is beyond the booking window"})
+		return
+	}
+
+	itemCondition, itemArgs := itemFilter{IDs: []string{itemID}}.where(4)
+	days, err := loadCalendar(models.DB, from, to, loc, itemCondition, itemArgs)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"item_id":   itemID,
+		"time_zone": loc.String(),
+		"from":      from.Format("2006-01-02"),
+		"to":        to.Format("2006-01-02"),
+		"days":      days,
+	})
+}
+
+// parseAvailableDays читает параметр days — на сколько дней вперёд искать свободные даты.
+// По умолчанию defaultAvailableDays, но не больше booking_window_days
+func parseAvailableDays(r *http.Request) (int, error) {
+	days := defaultAvailableDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid days %q", v)
+		}
+		days = n
+	}
+	if days > models.BookingWindowDays {
+		days = models.BookingWindowDays
+	}
+	return days, nil
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"booking-system/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Коды нарушений правил отмены
+const (
+	ViolationCancelStarted  = "booking_started"
+	ViolationCancelDeadline = "cancel_deadline_passed"
+)
+
+// lateCancelActions — допустимые реакции на позднюю отмену
+var lateCancelActions = map[string]bool{
+	"penalty": true,
+	"refuse":  true,
+}
+
+// itemCancellationPolicy возвращает правила отмены объекта с учётом его переопределений
+func itemCancellationPolicy(freeCancelHours *int, lateCancelAction *string) models.CancellationPolicy {
+	policy := models.CancellationPolicy{
+		FreeCancelHours:  models.FreeCancelHours,
+		LateCancelAction: models.LateCancelAction,
+	}
+	if freeCancelHours != nil {
+		policy.FreeCancelHours = *freeCancelHours
+	}
+	if lateCancelAction != nil {
+		policy.LateCancelAction = *lateCancelAction
+	}
+	return policy
+}
+
+// cancellationCheck — результат проверки отмены бронирования, начинающегося в start
+type cancellationCheck struct {
+	Cancellable bool
+	Late        bool
+	Deadline    time.Time
+}
+
+// checkCancellation применяет правила отмены к бронированию со статусом status.
+// Ожидающая подтверждения заявка отменяется без штрафа в любой момент до начала слота
+func checkCancellation(policy models.CancellationPolicy, status string, start, now time.Time) cancellationCheck {
+	check := cancellationCheck{
+		Deadline: start.Add(-time.Duration(policy.FreeCancelHours) * time.Hour),
+	}
+	if (status != "confirmed" && status != "pending") || !start.After(now) {
+		return check
+	}
+	if status == "pending" || now.Before(check.Deadline) {
+		check.Cancellable = true
+		return check
+	}
+	check.Late = true
+	check.Cancellable = policy.LateCancelAction == "penalty"
+	return check
+}
+
+// cancellationViolation применяет правила отмены к бронированию и возвращает ошибку с нарушением,
+// если его нельзя отменить сейчас. action — что делается с бронированием, для текста нарушения;
+// lateAllowed — допускается ли поздняя отмена со штрафом
+func cancellationViolation(policy models.CancellationPolicy, status string, start, now time.Time, action string, lateAllowed bool) (cancellationCheck, error) {
+	check := checkCancellation(policy, status, start, now)
+	if (status == "confirmed" || status == "pending") && !start.After(now) {
+		return check, &bookingError{
+			Status:  http.StatusForbidden,
+			Message: "Cancellation rules violated",
+			Violations: []models.PolicyViolation{{
+				Code:    ViolationCancelStarted,
+				Message: "Booking has already started",
+			}},
+		}
+	}
+	if check.Late && (!check.Cancellable || !lateAllowed) {
+		return check, &bookingError{
+			Status:  http.StatusForbidden,
+			Message: "Cancellation rules violated",
+			Violations: []models.PolicyViolation{{
+				Code:    ViolationCancelDeadline,
+				Message: fmt.Sprintf("Bookings must be %s at least %d hours in advance", action, policy.FreeCancelHours),
+			}},
+		}
+	}
+	return check, nil
+}
+
+// cancelBookingByUser отменяет бронирование по инициативе пользователя с учётом правил отмены.
+// Поздняя отмена либо фиксируется как штраф, либо отклоняется. Бронирование из комплекта отменяется
+// вместе с остальными, поэтому правила проверяются для каждого из них и действует самое строгое.
+// Возвращает true, если начислен штраф
+func cancelBookingByUser(tx *sql.Tx, bookingID, reason string) (bool, error) {
+	rows, err := tx.Query(`
+		SELECT b.id, b.user_id, b.status, bs.starts_at, bi.free_cancel_hours, bi.late_cancel_action
+		FROM bookings b
+		JOIN booking_slots bs ON b.slot_id = bs.id
+		JOIN booking_items bi ON bs.item_id = bi.id
+		WHERE b.id = $1
+		   OR (b.bundle_booking_id = (SELECT bundle_booking_id FROM bookings WHERE id = $1)
+		       AND b.status IN ('confirmed', 'pending'))
+		ORDER BY b.id
+		FOR UPDATE OF b
+	`, bookingID)
+	if err != nil {
+		return false, err
+	}
+
+	type member struct {
+		userID, status string
+		start          time.Time
+		policy         models.CancellationPolicy
+	}
+	var members []member
+	found := false
+	for rows.Next() {
+		var id string
+		var m member
+		var freeCancelHours *int
+		var lateCancelAction *string
+		if err := rows.Scan(&id, &m.userID, &m.status, &m.start, &freeCancelHours, &lateCancelAction); err != nil {
+			rows.Close()
+			return false, err
+		}
+		m.policy = itemCancellationPolicy(freeCancelHours, lateCancelAction)
+		found = found || id == bookingID
+		members = append(members, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	if !found {
+		return false, &bookingError{Status: http.StatusNotFound, Message: "Booking not found"}
+	}
+
+	now := time.Now()
+	late := false
+	var lateStart time.Time
+	for _, m := range members {
+		check, err := cancellationViolation(m.policy, m.status, m.start, now, "cancelled", true)
+		if err != nil {
+			return false, err
+		}
+		if check.Late && (!late || m.start.Before(lateStart)) {
+			late, lateStart = true, m.start
+		}
+	}
+
+	if err := cancelBooking(tx, bookingID, reason); err != nil {
+		return false, err
+	}
+
+	if !late {
+		return false, nil
+	}
+
+	// Штраф за отмену комплекта один, по ближайшему из поздно отменённых бронирований
+	_, err = tx.Exec(`
+		INSERT INTO cancellation_penalties (user_id, booking_id, hours_before_start)
+		VALUES ($1, $2, $3)
+	`, members[0].userID, bookingID, lateStart.Sub(now).Hours())
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func ApiGetCancellationPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	itemID := r.URL.Query().Get("item_id")
+	if itemID == "" {
+		respondWithJSON(w, http.StatusOK, itemCancellationPolicy(nil, nil))
+		return
+	}
+
+	var freeCancelHours *int
+	var lateCancelAction *string
+	err := models.DB.QueryRow(`
+		SELECT free_cancel_hours, late_cancel_action FROM booking_items WHERE id = $1
+	`, itemID).Scan(&freeCancelHours, &lateCancelAction)
+	if err != nil {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Item not found"})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, itemCancellationPolicy(freeCancelHours, lateCancelAction))
+}
+
+func ApiUpdateItemCancellationPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	role, ok := session.Values["role"].(string)
+	if !ok || role != "admin" {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	vars := mux.Vars(r)
+	itemID := vars["id"]
+
+	// null в поле возвращает объекту глобальное значение
+	var req struct {
+		FreeCancelHours  *int    `json:"free_cancel_hours"`
+		LateCancelAction *string `json:"late_cancel_action"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	if req.FreeCancelHours != nil && *req.FreeCancelHours < 0 {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Free cancellation hours cannot be negative"})
+		return
+	}
+	if req.LateCancelAction != nil && !lateCancelActions[*req.LateCancelAction] {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Late cancel action must be 'penalty' or 'refuse'"})
+		return
+	}
+
+	result, err := models.DB.Exec(`
+		UPDATE booking_items SET free_cancel_hours = $2, late_cancel_action = $3 WHERE id = $1
+	`, itemID, req.FreeCancelHours, req.LateCancelAction)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Item not found"})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, itemCancellationPolicy(req.FreeCancelHours, req.LateCancelAction))
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"booking-system/models"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// checkInWindow возвращает интервал самостоятельной отметки о приходе для слота [start, end):
+// от check_in_window_minutes до начала до истечения no_show_grace_minutes после начала
+func checkInWindow(start, end time.Time) (time.Time, time.Time) {
+	opens := start.Add(-time.Duration(models.CheckInWindowMinutes) * time.Minute)
+	closes := start.Add(time.Duration(models.NoShowGraceMinutes) * time.Minute)
+	if end.Before(closes) {
+		closes = end
+	}
+	return opens, closes
+}
+
+// checkInBooking отмечает приход по бронированию. Пользователь может отметиться только в окне check-in,
+// менеджер — в любой момент после открытия окна, в том числе исправляя ошибочно зафиксированную неявку
+func checkInBooking(tx *sql.Tx, bookingID, actorID string, byManager bool) error {
+	var status string
+	var start, end time.Time
+	var checkedIn bool
+	err := tx.QueryRow(`
+		SELECT b.status, b.checked_in_at IS NOT NULL, bs.starts_at, `+bookingEndsAtSQL+`
+		FROM bookings b
+		JOIN booking_slots bs ON b.slot_id = bs.id
+		WHERE b.id = $1
+		FOR UPDATE OF b
+	`, bookingID).Scan(&status, &checkedIn, &start, &end)
+	if err == sql.ErrNoRows {
+		return &bookingError{Status: http.StatusNotFound, Message: "Booking not found"}
+	}
+	if err != nil {
+		return err
+	}
+
+	if checkedIn {
+		return &bookingError{Status: http.StatusConflict, Message: "Already checked in"}
+	}
+
+	opens, closes := checkInWindow(start, end)
+	now := time.Now()
+
+	if byManager {
+		if status != "confirmed" && status != "no_show" {
+			return &bookingError{Status: http.StatusConflict, Message: "Only confirmed bookings can be checked in"}
+		}
+		if now.Before(opens) {
+			return &bookingError{Status: http.StatusConflict, Message: "Check-in is not open yet"}
+		}
+	} else {
+		if status != "confirmed" {
+			return &bookingError{Status: http.StatusConflict, Message: "Only confirmed bookings can be checked in"}
+		}
+		if now.Before(opens) || !now.Before(closes) {
+			return &bookingError{Status: http.StatusConflict, Message: "Check-in is only possible around the start time"}
+		}
+	}
+
+	newStatus := "confirmed"
+	if !now.Before(end) {
+		newStatus = "completed"
+	}
+	_, err = tx.Exec(`
+		UPDATE bookings SET status = $2, checked_in_at = NOW(), updated_at = NOW() WHERE id = $1
+	`, bookingID, newStatus)
+	if err != nil {
+		return err
+	}
+
+	return logBookingEvent(tx, bookingID, "checked_in", nil, nil, actorID)
+}
+
+// markNoShows фиксирует неявку по подтверждённым бронированиям без отметки о приходе,
+// если после начала слота прошло no_show_grace_minutes или слот уже закончился.
+// Работает, только если отметка о приходе обязательна, и лишь для бронирований, созданных после её включения:
+// остальные завершаются как обычно
+func markNoShows() error {
+	if !models.CheckInRequired {
+		return nil
+	}
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		UPDATE bookings b SET status = 'no_show', updated_at = NOW()
+		FROM booking_slots bs
+		JOIN booking_items bi ON bs.item_id = bi.id
+		WHERE b.slot_id = bs.id AND b.status = 'confirmed' AND b.checked_in_at IS NULL
+		  AND b.created_at >= (SELECT check_in_required_since FROM system_settings WHERE check_in_required LIMIT 1)
+		  AND (`+slotStartsAtSQL+` + make_interval(mins => $1) <= NOW() OR `+bookingEndsAtSQL+` <= NOW())
+		RETURNING b.user_id, bi.name, to_char(bs.date, 'YYYY-MM-DD'), bs.start_time::text
+	`, models.NoShowGraceMinutes)
+	if err != nil {
+		return err
+	}
+
+	type noShow struct {
+		UserID, ItemName, Date, StartTime string
+	}
+	var noShows []noShow
+	for rows.Next() {
+		var n noShow
+		if err := rows.Scan(&n.UserID, &n.ItemName, &n.Date, &n.StartTime); err != nil {
+			rows.Close()
+			return err
+		}
+		noShows = append(noShows, n)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, n := range noShows {
+		message := fmt.Sprintf("You did not check in for %s on %s at %s. The booking was marked as a no-show.",
+			n.ItemName, n.Date, n.StartTime)
+		if err := notifyUser(tx, n.UserID, message); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func ApiCheckInHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	userID, ok := session.Values["user_id"].(string)
+	if !ok {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	vars := mux.Vars(r)
+	bookingID := vars["id"]
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	var owner string
+	err = tx.QueryRow("SELECT user_id FROM bookings WHERE id = $1", bookingID).Scan(&owner)
+	if err != nil || owner != userID {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Booking not found"})
+		return
+	}
+
+	if err := checkInBooking(tx, bookingID, userID, false); err != nil {
+		respondBookingError(w, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func ApiManagerCheckInHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	role, ok := session.Values["role"].(string)
+	if !ok || (role != "admin" && role != "manager") {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+	actorID, _ := session.Values["user_id"].(string)
+
+	vars := mux.Vars(r)
+	bookingID := vars["id"]
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	if err := checkInBooking(tx, bookingID, actorID, true); err != nil {
+		respondBookingError(w, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
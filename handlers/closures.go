@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"booking-system/models"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+var closureKinds = map[string]bool{
+	"holiday":       true,
+	"maintenance":   true,
+	"private_event": true,
+	"other":         true,
+}
+
+// parseLocalDateTime разбирает момент времени в RFC 3339 или «настенное» время в поясе сервера БД
+func parseLocalDateTime(s string, endOfDay bool) (time.Time, error) {
+	return parseDateTimeIn(s, endOfDay, models.DBLocation)
+}
+
+// findClosureConflicts возвращает активные бронирования, пересекающиеся с интервалом закрытия
+func findClosureConflicts(q queryer, startsAt, endsAt time.Time, itemID *string) ([]models.BookingConflict, error) {
+	rows, err := q.Query(`
+		SELECT b.id, b.user_id, u.login, bi.name, to_char(bs.date, 'YYYY-MM-DD'), bs.start_time::text, `+bookingEndTimeSQL+`
+		FROM bookings b
+		JOIN booking_slots bs ON b.slot_id = bs.id
+		JOIN booking_items bi ON bs.item_id = bi.id
+		JOIN users u ON b.user_id = u.id
+		WHERE b.status IN ('confirmed', 'pending')
+		  AND ($3::uuid IS NULL OR bs.item_id = $3::uuid)
+		  AND `+slotStartsAtSQL+` < $2 AND `+bookingEndsAtSQL+` > $1
+		ORDER BY bs.date, bs.start_time
+	`, startsAt, endsAt, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conflicts := []models.BookingConflict{}
+	for rows.Next() {
+		var c models.BookingConflict
+		if err := rows.Scan(&c.BookingID, &c.UserID, &c.UserLogin, &c.ItemName, &c.Date, &c.StartTime, &c.EndTime); err != nil {
+			return nil, err
+		}
+		conflicts = append(conflicts, c)
+	}
+	return conflicts, rows.Err()
+}
+
+func ApiGetClosuresHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var from, to interface{}
+	if v := query.Get("from"); v != "" {
+		t, err := parseLocalDateTime(v, false)
+		if err != nil {
+			respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		from = t
+	}
+	if v := query.Get("to"); v != "" {
+		t, err := parseLocalDateTime(v, true)
+		if err != nil {
+			respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		to = t
+	}
+	var itemID interface{}
+	if v := query.Get("item_id"); v != "" {
+		if _, err := uuid.Parse(v); err != nil {
+			respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid item_id"})
+			return
+		}
+		itemID = v
+	}
+
+	rows, err := models.DB.Query(`
+		SELECT id, name, kind, starts_at, ends_at, item_id, created_at
+		FROM closures
+		WHERE ($1::timestamptz IS NULL OR ends_at > $1::timestamptz)
+		  AND ($2::timestamptz IS NULL OR starts_at < $2::timestamptz)
+		  AND ($3::uuid IS NULL OR item_id IS NULL OR item_id = $3::uuid)
+		ORDER BY starts_at
+	`, from, to, itemID)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	closures := []models.Closure{}
+	for rows.Next() {
+		var c models.Closure
+		var startsAt, endsAt time.Time
+		rows.Scan(&c.ID, &c.Name, &c.Kind, &startsAt, &endsAt, &c.ItemID, &c.CreatedAt)
+		c.StartsAt, c.EndsAt = startsAt.Format(time.RFC3339), endsAt.Format(time.RFC3339)
+		closures = append(closures, c)
+	}
+
+	respondWithJSON(w, http.StatusOK, closures)
+}
+
+func ApiCreateClosureHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	role, ok := session.Values["role"].(string)
+	if !ok || (role != "admin" && role != "manager") {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+	userID, _ := session.Values["user_id"].(string)
+
+	// cancel_conflicts: отменить пересекающиеся бронирования и уведомить пользователей;
+	// иначе закрытие создаётся, а конфликты только возвращаются в ответе
+	var req struct {
+		Name            string  `json:"name"`
+		Kind            string  `json:"kind"`
+		StartsAt        string  `json:"starts_at"`
+		EndsAt          string  `json:"ends_at"`
+		ItemID          *string `json:"item_id"`
+		CancelConflicts bool    `json:"cancel_conflicts"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	if req.Name == "" {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Name is required"})
+		return
+	}
+	if req.Kind == "" {
+		req.Kind = "other"
+	}
+	if !closureKinds[req.Kind] {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Unknown closure kind: " + req.Kind})
+		return
+	}
+	if req.ItemID != nil && *req.ItemID == "" {
+		req.ItemID = nil
+	}
+	if req.ItemID != nil {
+		if _, err := uuid.Parse(*req.ItemID); err != nil {
+			respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid item_id"})
+			return
+		}
+	}
+
+	// Время без смещения задаётся в поясе объекта, а для общего закрытия — в поясе сервера БД
+	loc := models.DBLocation
+	if req.ItemID != nil {
+		itemLoc, err := itemLocation(models.DB, *req.ItemID)
+		if err != nil {
+			respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Item not found"})
+			return
+		}
+		loc = itemLoc
+	}
+
+	startsAt, err := parseDateTimeIn(req.StartsAt, false, loc)
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid starts_at: " + err.Error()})
+		return
+	}
+	endsAt, err := parseDateTimeIn(req.EndsAt, true, loc)
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid ends_at: " + err.Error()})
+		return
+	}
+	if !endsAt.After(startsAt) {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Closure must end after it starts"})
+		return
+	}
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	var closureID string
+	err = tx.QueryRow(`
+		INSERT INTO closures (name, kind, starts_at, ends_at, item_id, created_by)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, '')::uuid)
+		RETURNING id
+	`, req.Name, req.Kind, startsAt, endsAt, req.ItemID, userID).Scan(&closureID)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Could not create closure: " + err.Error()})
+		return
+	}
+
+	conflicts, err := findClosureConflicts(tx, startsAt, endsAt, req.ItemID)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	cancelled := 0
+	if req.CancelConflicts {
+		for _, c := range conflicts {
+			var be *bookingError
+			err := cancelBooking(tx, c.BookingID.String(), req.Name)
+			if errors.As(err, &be) && be.Status == http.StatusConflict {
+				// Уже отменено вместе с другим бронированием комплекта
+				continue
+			}
+			if err != nil {
+				respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+			message := fmt.Sprintf("Your booking of %s on %s at %s was cancelled: %s",
+				c.ItemName, c.Date, c.StartTime, req.Name)
+			if err := notifyUser(tx, c.UserID.String(), message); err != nil {
+				respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+			cancelled++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"id":        closureID,
+		"conflicts": conflicts,
+		"cancelled": cancelled,
+	})
+}
+
+func ApiGetClosureConflictsHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	role, ok := session.Values["role"].(string)
+	if !ok || (role != "admin" && role != "manager") {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	vars := mux.Vars(r)
+	closureID := vars["id"]
+	if _, err := uuid.Parse(closureID); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid closure ID"})
+		return
+	}
+
+	var startsAt, endsAt time.Time
+	var itemID sql.NullString
+	err := models.DB.QueryRow(`
+		SELECT starts_at, ends_at, item_id FROM closures WHERE id = $1
+	`, closureID).Scan(&startsAt, &endsAt, &itemID)
+	if err != nil {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Closure not found"})
+		return
+	}
+
+	var item *string
+	if itemID.Valid {
+		item = &itemID.String
+	}
+
+	conflicts, err := findClosureConflicts(models.DB, startsAt, endsAt, item)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, conflicts)
+}
+
+func ApiDeleteClosureHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	role, ok := session.Values["role"].(string)
+	if !ok || (role != "admin" && role != "manager") {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	vars := mux.Vars(r)
+	closureID := vars["id"]
+	if _, err := uuid.Parse(closureID); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid closure ID"})
+		return
+	}
+
+	_, err := models.DB.Exec("DELETE FROM closures WHERE id = $1", closureID)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
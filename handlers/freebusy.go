@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"booking-system/models"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// maxFreeBusyUsers — сколько пользователей можно запросить за один раз
+const maxFreeBusyUsers = 50
+
+// busyInterval — интервал занятости пользователя одним бронированием
+type busyInterval struct {
+	Start, End time.Time
+	BookingID  uuid.UUID
+	ItemID     uuid.UUID
+	ItemName   string
+}
+
+// freeBusyRequest — общие параметры запросов занятости: пользователи и интервал времени
+type freeBusyRequest struct {
+	UserIDs []string
+	From    time.Time
+	To      time.Time
+}
+
+// parseFreeBusyRequest читает user_id=a,b и интервал from/to. Время без пояса понимается в поясе loc
+func parseFreeBusyRequest(r *http.Request, loc *time.Location) (freeBusyRequest, error) {
+	query := r.URL.Query()
+	var req freeBusyRequest
+
+	for _, id := range strings.Split(query.Get("user_id"), ",") {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if _, err := uuid.Parse(id); err != nil {
+			return req, fmt.Errorf("invalid user_id %q", id)
+		}
+		req.UserIDs = append(req.UserIDs, id)
+	}
+	if len(req.UserIDs) == 0 {
+		return req, fmt.Errorf("user_id is required")
+	}
+	if len(req.UserIDs) > maxFreeBusyUsers {
+		return req, fmt.Errorf("at most %d users can be requested at once", maxFreeBusyUsers)
+	}
+
+	var err error
+	if req.From, err = parseDateTimeIn(query.Get("from"), false, loc); err != nil {
+		return req, fmt.Errorf("invalid from")
+	}
+	if req.To, err = parseDateTimeIn(query.Get("to"), true, loc); err != nil {
+		return req, fmt.Errorf("invalid to")
+	}
+	if !req.To.After(req.From) {
+		return req, fmt.Errorf("to must be after from")
+	}
+	if req.To.Sub(req.From) > maxSearchDays*24*time.Hour {
+		return req, fmt.Errorf("range cannot exceed %d days", maxSearchDays)
+	}
+	return req, nil
+}
+
+// canViewFreeBusy — занятость пользователей userIDs доступна менеджерам и пользователю, который сам среди них
+func canViewFreeBusy(callerID string, isManager bool, userIDs []string) bool {
+	if isManager {
+		return true
+	}
+	for _, id := range userIDs {
+		if id == callerID {
+			return true
+		}
+	}
+	return false
+}
+
+// loadUserLogins возвращает логины пользователей; ошибка, если кого-то из них нет
+func loadUserLogins(q queryer, userIDs []string) (map[string]string, error) {
+	rows, err := q.Query("SELECT id, login FROM users WHERE id = ANY($1::uuid[])", pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	logins := map[string]string{}
+	for rows.Next() {
+		var id, login string
+		if err := rows.Scan(&id, &login); err != nil {
+			return nil, err
+		}
+		logins[id] = login
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, id := range userIDs {
+		if _, ok := logins[id]; !ok {
+			return nil, &bookingError{Status: http.StatusNotFound, Message: fmt.Sprintf("User %s not found", id)}
+		}
+	}
+	return logins, nil
+}
+
+// loadBusy возвращает подтверждённые бронирования пользователей, пересекающиеся с [from, to),
+// сгруппированные по пользователям и упорядоченные по началу
+func loadBusy(q queryer, userIDs []string, from, to time.Time) (map[string][]busyInterval, error) {
+	rows, err := q.Query(`
+		SELECT b.user_id, b.id, bi.id, bi.name, bs.starts_at, `+bookingEndsAtSQL+`
+		FROM bookings b
+		JOIN booking_slots bs ON b.slot_id = bs.id
+		JOIN booking_items bi ON bs.item_id = bi.id
+		WHERE b.user_id = ANY($1::uuid[]) AND b.status = 'confirmed'
+		  AND bs.starts_at < $3 AND `+bookingEndsAtSQL+` > $2
+		ORDER BY b.user_id, bs.starts_at
+	`, pq.Array(userIDs), from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	busy := map[string][]busyInterval{}
+	for rows.Next() {
+		var userID string
+		var b busyInterval
+		if err := rows.Scan(&userID, &b.BookingID, &b.ItemID, &b.ItemName, &b.Start, &b.End); err != nil {
+			return nil, err
+		}
+		busy[userID] = append(busy[userID], b)
+	}
+	return busy, rows.Err()
+}
+
+// mergeBusy объединяет пересекающиеся и смежные интервалы занятости, отбрасывая подробности бронирований
+func mergeBusy(intervals []busyInterval) []busyInterval {
+	sorted := make([]busyInterval, len(intervals))
+	copy(sorted, intervals)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	var merged []busyInterval
+	for _, b := range sorted {
+		if n := len(merged); n > 0 && !b.Start.After(merged[n-1].End) {
+			if b.End.After(merged[n-1].End) {
+				merged[n-1].End = b.End
+			}
+			continue
+		}
+		merged = append(merged, busyInterval{Start: b.Start, End: b.End})
+	}
+	return merged
+}
+
+// ApiGetFreeBusyHandler возвращает интервалы занятости пользователей на интервале from/to.
+// Обычные пользователи запрашивают только группы, в которые входят сами, и видят объединённые интервалы;
+// менеджеры — любых пользователей, и ещё бронирования с объектами
+func ApiGetFreeBusyHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	callerID, ok := session.Values["user_id"].(string)
+	if !ok {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+	role, _ := session.Values["role"].(string)
+	isManager := role == "admin" || role == "manager"
+
+	loc := userLocation(models.DB, callerID)
+	req, err := parseFreeBusyRequest(r, loc)
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if !canViewFreeBusy(callerID, isManager, req.UserIDs) {
+		respondWithJSON(w, http.StatusForbidden, map[string]string{"error": "Forbidden"})
+		return
+	}
+
+	logins, err := loadUserLogins(models.DB, req.UserIDs)
+	if err != nil {
+		respondBookingError(w, err)
+		return
+	}
+	busy, err := loadBusy(models.DB, req.UserIDs, req.From, req.To)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	users := []models.UserFreeBusy{}
+	for _, id := range req.UserIDs {
+		intervals := busy[id]
+		if !isManager {
+			intervals = mergeBusy(intervals)
+		}
+
+		user := models.UserFreeBusy{UserID: uuid.MustParse(id), Login: logins[id], Busy: []models.BusyInterval{}}
+		for _, b := range intervals {
+			interval := models.BusyInterval{
+				StartsAt: b.Start.In(loc).Format(time.RFC3339),
+				EndsAt:   b.End.In(loc).Format(time.RFC3339),
+			}
+			if isManager {
+				bookingID, itemID := b.BookingID, b.ItemID
+				interval.BookingID, interval.ItemID, interval.ItemName = &bookingID, &itemID, b.ItemName
+			}
+			user.Busy = append(user.Busy, interval)
+		}
+		users = append(users, user)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"from":      req.From.In(loc).Format(time.RFC3339),
+		"to":        req.To.In(loc).Format(time.RFC3339),
+		"time_zone": loc.String(),
+		"users":     users,
+	})
+}
+
+// ApiFindCommonFreeTimeHandler ищет интервалы длительностью не меньше duration минут, когда все пользователи
+// свободны, а в объекте item_id подряд идут открытые слоты с participants местами (по умолчанию — по числу пользователей).
+// Доступ к группе пользователей — как в ApiGetFreeBusyHandler
+func ApiFindCommonFreeTimeHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	callerID, ok := session.Values["user_id"].(string)
+	if !ok {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	role, _ := session.Values["role"].(string)
+
+	req, err := parseFreeBusyRequest(r, userLocation(models.DB, callerID))
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if !canViewFreeBusy(callerID, role == "admin" || role == "manager", req.UserIDs) {
+		respondWithJSON(w, http.StatusForbidden, map[string]string{"error": "Forbidden"})
+		return
+	}
+
+	query := r.URL.Query()
+	itemID := query.Get("item_id")
+	if itemID == "" {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "item_id is required"})
+		return
+	}
+	loc, err := itemLocation(models.DB, itemID)
+	if err != nil {
+		respondBookingError(w, err)
+		return
+	}
+
+	duration, participants, err := parseWindowParams(query, len(req.UserIDs))
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if _, err := loadUserLogins(models.DB, req.UserIDs); err != nil {
+		respondBookingError(w, err)
+		return
+	}
+	busy, err := loadBusy(models.DB, req.UserIDs, req.From, req.To)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	var all []busyInterval
+	for _, intervals := range busy {
+		all = append(all, intervals...)
+	}
+	all = mergeBusy(all)
+
+	itemCondition, itemArgs := itemFilter{IDs: []string{itemID}}.where(4)
+	itemSlots, err := loadFreeSlots(models.DB, req.From, req.To, participants, itemCondition, itemArgs)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Остаются только слоты, не пересекающиеся ни с чьей занятостью
+	var free []searchSlot
+	for _, slots := range itemSlots {
+		for _, s := range slots {
+			overlaps := false
+			for _, b := range all {
+				if b.Start.Before(s.EndsAt) && b.End.After(s.StartsAt) {
+					overlaps = true
+					break
+				}
+			}
+			if !overlaps {
+				free = append(free, s)
+			}
+		}
+	}
+
+	windows := collectWindows(free, duration)
+	if windows == nil {
+		windows = []models.AvailabilityWindow{}
+	}
+	if len(windows) > maxWindowsPerItem {
+		windows = windows[:maxWindowsPerItem]
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"item_id":   itemID,
+		"time_zone": loc.String(),
+		"user_ids":  req.UserIDs,
+		"windows":   windows,
+	})
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestMergeBusy(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 5, 6, hour, minute, 0, 0, time.UTC)
+	}
+	interval := func(startHour, startMinute, endHour, endMinute int) busyInterval {
+		return busyInterval{
+			Start:     at(startHour, startMinute),
+			End:       at(endHour, endMinute),
+			BookingID: uuid.New(),
+			ItemID:    uuid.New(),
+			ItemName:  "Room",
+		}
+	}
+
+	tests := []struct {
+		name      string
+		intervals []busyInterval
+		want      [][2]time.Time
+	}{
+		{
+			name:      "empty",
+			intervals: nil,
+			want:      nil,
+		},
+		{
+			name:      "separate intervals stay apart",
+			intervals: []busyInterval{interval(9, 0, 10, 0), interval(11, 0, 12, 0)},
+			want:      [][2]time.Time{{at(9, 0), at(10, 0)}, {at(11, 0), at(12, 0)}},
+		},
+		{
+			name:      "overlapping intervals are joined",
+			intervals: []busyInterval{interval(9, 0, 10, 30), interval(10, 0, 11, 0)},
+			want:      [][2]time.Time{{at(9, 0), at(11, 0)}},
+		},
+		{
+			name:      "adjacent intervals are joined",
+			intervals: []busyInterval{interval(9, 0, 10, 0), interval(10, 0, 11, 0)},
+			want:      [][2]time.Time{{at(9, 0), at(11, 0)}},
+		},
+		{
+			name:      "nested interval is absorbed",
+			intervals: []busyInterval{interval(9, 0, 12, 0), interval(10, 0, 11, 0)},
+			want:      [][2]time.Time{{at(9, 0), at(12, 0)}},
+		},
+		{
+			name:      "unsorted input",
+			intervals: []busyInterval{interval(14, 0, 15, 0), interval(9, 0, 10, 0), interval(9, 30, 11, 0)},
+			want:      [][2]time.Time{{at(9, 0), at(11, 0)}, {at(14, 0), at(15, 0)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeBusy(tt.intervals)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d intervals, want %d", len(got), len(tt.want))
+			}
+			for i, b := range got {
+				if !b.Start.Equal(tt.want[i][0]) || !b.End.Equal(tt.want[i][1]) {
+					t.Errorf("interval %d: got %s-%s, want %s-%s", i, b.Start, b.End, tt.want[i][0], tt.want[i][1])
+				}
+				if b.BookingID != uuid.Nil || b.ItemID != uuid.Nil || b.ItemName != "" {
+					t.Errorf("interval %d exposes booking details: %+v", i, b)
+				}
+			}
+		})
+	}
+
+	// Исходный список не меняется
+	original := []busyInterval{interval(11, 0, 12, 0), interval(9, 0, 10, 0)}
+	mergeBusy(original)
+	if !original[0].Start.Equal(at(11, 0)) {
+		t.Errorf("mergeBusy reordered its input")
+	}
+}
+
+func TestCanViewFreeBusy(t *testing.T) {
+	caller, other := uuid.NewString(), uuid.NewString()
+
+	tests := []struct {
+		name      string
+		isManager bool
+		userIDs   []string
+		want      bool
+	}{
+		{name: "own busy time", userIDs: []string{caller}, want: true},
+		{name: "group with the caller", userIDs: []string{other, caller}, want: true},
+		{name: "other users only", userIDs: []string{other}, want: false},
+		{name: "manager sees anyone", isManager: true, userIDs: []string{other}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canViewFreeBusy(caller, tt.isManager, tt.userIDs); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
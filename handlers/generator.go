@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"booking-system/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// maxGenerationDays ограничивает диапазон дат, для которого за раз генерируются слоты
+const maxGenerationDays = 366
+
+// clockRange — интервал времени внутри дня в минутах от полуночи
+type clockRange struct {
+	Start int
+	End   int
+}
+
+func (c clockRange) overlaps(other clockRange) bool {
+	return c.Start < other.End && other.Start < c.End
+}
+
+// parseClock разбирает время в формате "15:04" или "15:04:05" в минуты от полуночи
+func parseClock(s string) (int, error) {
+	layout := "15:04"
+	if strings.Count(s, ":") == 2 {
+		layout = "15:04:05"
+	}
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// formatClock форматирует минуты от полуночи как "15:04"
+func formatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// itemBuffers — время подготовки до и уборки после бронирования объекта, в минутах
+type itemBuffers struct {
+	Before int
+	After  int
+}
+
+// pad расширяет интервал на буферы
+func (b itemBuffers) pad(c clockRange) clockRange {
+	return clockRange{Start: c.Start - b.Before, End: c.End + b.After}
+}
+
+// gap — минимальный промежуток между соседними слотами объекта
+func (b itemBuffers) gap() int {
+	return b.Before + b.After
+}
+
+func loadItemBuffers(q queryer, itemID string) (itemBuffers, error) {
+	var b itemBuffers
+	err := q.QueryRow(`
+		SELECT buffer_before_minutes, buffer_after_minutes FROM booking_items WHERE id = $1
+	`, itemID).Scan(&b.Before, &b.After)
+	return b, err
+}
+
+// splitIntoSlots нарезает интервал на слоты длительностью duration минут.
+// Буферы объекта остаются между слотами и внутри интервала, сами слоты их не включают
+func splitIntoSlots(window clockRange, duration int, buffers itemBuffers) []clockRange {
+	var slots []clockRange
+	if duration <= 0 {
+		return slots
+	}
+	for start := window.Start + buffers.Before; start+duration+buffers.After <= window.End; start += duration + buffers.gap() {
+		slots = append(slots, clockRange{Start: start, End: start + duration})
+	}
+	return slots
+}
+
+// existsInZone сообщает, что слот дня day существует в поясе loc без искажений: его начало и конец
+// не попадают в час, пропущенный при переходе на летнее время, а реальная длительность равна номинальной
+// (слот не растягивается повторяющимся часом при переходе на зимнее время)
+func existsInZone(day time.Time, slot clockRange, loc *time.Location) bool {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, slot.Start, 0, 0, loc)
+	end := time.Date(day.Year(), day.Month(), day.Day(), 0, slot.End, 0, 0, loc)
+	if start.Hour()*60+start.Minute() != slot.Start {
+		return false
+	}
+	if end.Hour()*60+end.Minute() != slot.End%(24*60) {
+		return false
+	}
+	return end.Sub(start) == time.Duration(slot.End-slot.Start)*time.Minute
+}
+
+// generateSlots создаёт слоты объекта на даты from..to включительно по расписанию объекта
+// или системным настройкам, пропуская слоты, которые уже существуют или вместе с буферами пересекаются с существующими.
+// Слоты, искажённые переходом на летнее или зимнее время в поясе объекта, тоже пропускаются
+func generateSlots(tx *sql.Tx, itemID string, from, to time.Time) (created, skipped int, err error) {
+	// Блокируем объект, чтобы параллельная генерация не создала дубликаты
+	var lockedID string
+	err = tx.QueryRow("SELECT id FROM booking_items WHERE id = $1 FOR UPDATE", itemID).Scan(&lockedID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	schedule, err := loadItemSchedule(tx, itemID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	buffers, err := loadItemBuffers(tx, itemID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	loc, err := itemLocation(tx, itemID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	rows, err := tx.Query(`
+		SELECT to_char(date, 'YYYY-MM-DD'), start_time::text, end_time::text
+		FROM booking_slots
+		WHERE item_id = $1 AND date BETWEEN $2 AND $3 AND removed_at IS NULL
+	`, itemID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	existing := make(map[string][]clockRange)
+	for rows.Next() {
+		var date, start, end string
+		if err := rows.Scan(&date, &start, &end); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		s, _ := parseClock(start)
+		e, _ := parseClock(end)
+		existing[date] = append(existing[date], clockRange{Start: s, End: e})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		plan, err := daySlotPlan(schedule, day, buffers)
+		if err != nil {
+			return created, skipped, err
+		}
+
+	candidates:
+		for _, slot := range plan {
+			if !existsInZone(day, slot, loc) {
+				skipped++
+				continue
+			}
+			for _, other := range existing[date] {
+				if buffers.pad(slot).overlaps(buffers.pad(other)) {
+					skipped++
+					continue candidates
+				}
+			}
+
+			_, err := tx.Exec(`
+				INSERT INTO booking_slots (item_id, date, start_time, end_time, is_available, max_participants)
+				VALUES ($1, $2, $3, $4, true, COALESCE((SELECT capacity FROM booking_items WHERE id = $1), 1))
+			`, itemID, date, formatClock(slot.Start), formatClock(slot.End))
+			if err != nil {
+				return created, skipped, err
+			}
+			existing[date] = append(existing[date], slot)
+			created++
+		}
+	}
+
+	return created, skipped, nil
+}
+
+func ApiGenerateSlotsHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	role, ok := session.Values["role"].(string)
+	if !ok || (role != "admin" && role != "manager") {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	vars := mux.Vars(r)
+	itemID := vars["id"]
+	if _, err := uuid.Parse(itemID); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid item ID"})
+		return
+	}
+
+	var req struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	from, err := time.ParseInLocation("2006-01-02", req.From, time.Local)
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid 'from' date"})
+		return
+	}
+	to, err := time.ParseInLocation("2006-01-02", req.To, time.Local)
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid 'to' date"})
+		return
+	}
+	if to.Before(from) {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "'to' must not be before 'from'"})
+		return
+	}
+	if to.Sub(from) > maxGenerationDays*24*time.Hour {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("Date range must not exceed %d days", maxGenerationDays),
+		})
+		return
+	}
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	created, skipped, err := generateSlots(tx, itemID, from, to)
+	if err == sql.ErrNoRows {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Item not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Slot generation error: %v", err)
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]int{
+		"created": created,
+		"skipped": skipped,
+	})
+}
+
+// generateRollingSlots поддерживает сгенерированные слоты всех объектов
+// на горизонт окна бронирования вперёд
+func generateRollingSlots() error {
+	rows, err := models.DB.Query("SELECT id FROM booking_items")
+	if err != nil {
+		return err
+	}
+
+	var itemIDs []string
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		itemIDs = append(itemIDs, id)
+	}
+	rows.Close()
+
+	for _, itemID := range itemIDs {
+		// «Сегодня» считается в поясе объекта
+		loc, err := itemLocation(models.DB, itemID)
+		if err != nil {
+			log.Printf("Slot generation for item %s failed: %v", itemID, err)
+			continue
+		}
+		now := time.Now().In(loc)
+		from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+		to := from.AddDate(0, 0, models.BookingWindowDays)
+
+		tx, err := models.DB.Begin()
+		if err != nil {
+			return err
+		}
+
+		created, _, err := generateSlots(tx, itemID, from, to)
+		if err != nil {
+			tx.Rollback()
+			log.Printf("Slot generation for item %s failed: %v", itemID, err)
+			continue
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+
+		if created > 0 {
+			log.Printf("Generated %d slots for item %s", created, itemID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"booking-system/models"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maxActiveHolds — сколько удержаний одновременно может быть у пользователя
+const maxActiveHolds = 5
+
+// expireHolds удаляет просроченные удержания и передаёт освободившиеся места листу ожидания
+func expireHolds() error {
+	tx, err := models.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("DELETE FROM slot_holds WHERE expires_at <= NOW() RETURNING slot_id")
+	if err != nil {
+		return err
+	}
+
+	slots := make(map[string]bool)
+	for rows.Next() {
+		var slotID string
+		rows.Scan(&slotID)
+		slots[slotID] = true
+	}
+	rows.Close()
+
+	for slotID := range slots {
+		if err := promoteWaitlist(tx, slotID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func ApiCreateHoldHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	userID, ok := session.Values["user_id"].(string)
+	if !ok {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	vars := mux.Vars(r)
+	slotID := vars["id"]
+
+	var req struct {
+		Participants int `json:"participants"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	if req.Participants == 0 {
+		req.Participants = 1
+	}
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	// Повторное удержание того же слота заменяет предыдущее, а не складывается с ним
+	_, err = tx.Exec("DELETE FROM slot_holds WHERE user_id = $1 AND slot_id = $2", userID, slotID)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := validateSlotBooking(tx, userID, slotID, req.Participants, ""); err != nil {
+		respondBookingError(w, err)
+		return
+	}
+
+	var activeHolds int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM slot_holds WHERE user_id = $1 AND expires_at > NOW()
+	`, userID).Scan(&activeHolds)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if activeHolds >= maxActiveHolds {
+		respondWithJSON(w, http.StatusConflict, map[string]string{"error": "Too many active holds"})
+		return
+	}
+
+	var holdID string
+	var expiresAt time.Time
+	err = tx.QueryRow(`
+		INSERT INTO slot_holds (user_id, slot_id, participants, expires_at)
+		VALUES ($1, $2, $3, NOW() + make_interval(mins => $4))
+		RETURNING id, expires_at
+	`, userID, slotID, req.Participants, models.HoldMinutes).Scan(&holdID, &expiresAt)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"id":           holdID,
+		"slot_id":      slotID,
+		"participants": req.Participants,
+		"expires_at":   expiresAt.In(userLocation(models.DB, userID)).Format(time.RFC3339),
+	})
+}
+
+func ApiConfirmHoldHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	userID, ok := session.Values["user_id"].(string)
+	if !ok {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	vars := mux.Vars(r)
+	holdID := vars["id"]
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	var slotID string
+	var participants int
+	var expired bool
+	err = tx.QueryRow(`
+		SELECT slot_id, participants, expires_at <= NOW()
+		FROM slot_holds WHERE id = $1 AND user_id = $2
+		FOR UPDATE
+	`, holdID, userID).Scan(&slotID, &participants, &expired)
+	if err == sql.ErrNoRows {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Hold not found"})
+		return
+	}
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if expired {
+		respondWithJSON(w, http.StatusGone, map[string]string{"error": "Hold has expired"})
+		return
+	}
+
+	// Сначала снимаем удержание, чтобы его места не считались занятыми при бронировании
+	if _, err := tx.Exec("DELETE FROM slot_holds WHERE id = $1", holdID); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	bookingID, err := bookSlot(tx, userID, slotID, participants)
+	if err != nil {
+		respondBookingError(w, err)
+		return
+	}
+
+	var status string
+	if err := tx.QueryRow("SELECT status FROM bookings WHERE id = $1", bookingID).Scan(&status); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]string{"id": bookingID, "status": status})
+}
+
+func ApiReleaseHoldHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	userID, ok := session.Values["user_id"].(string)
+	if !ok {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	vars := mux.Vars(r)
+	holdID := vars["id"]
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	var slotID string
+	err = tx.QueryRow("DELETE FROM slot_holds WHERE id = $1 AND user_id = $2 RETURNING slot_id", holdID, userID).
+		Scan(&slotID)
+	if err == sql.ErrNoRows {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Hold not found"})
+		return
+	}
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := promoteWaitlist(tx, slotID); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,489 @@
+package handlers
+
+import (
+	"booking-system/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// bookingItemColumns — столбцы объекта bi в порядке, ожидаемом scanBookingItem
+const bookingItemColumns = `bi.id, bi.name, COALESCE(bi.description, ''), bi.capacity,
+	COALESCE(bi.location, ''), COALESCE(bi.category, ''), bi.location_id, bi.tags, bi.attributes,
+	bi.buffer_before_minutes, bi.buffer_after_minutes, bi.requires_approval, bi.pending_holds_seats,
+	COALESCE(bi.time_zone, ''), item_time_zone(bi.id)`
+
+// rowScanner — общий интерфейс для *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanBookingItem(row rowScanner) (models.BookingItem, error) {
+	var item models.BookingItem
+	var attributes []byte
+	err := row.Scan(&item.ID, &item.Name, &item.Description, &item.Capacity,
+		&item.Location, &item.Category, &item.LocationID, pq.Array(&item.Tags), &attributes,
+		&item.BufferBefore, &item.BufferAfter, &item.RequiresApproval, &item.PendingHoldsSeats,
+		&item.TimeZone, &item.EffectiveTimeZone)
+	if err != nil {
+		return item, err
+	}
+	if err := json.Unmarshal(attributes, &item.Attributes); err != nil {
+		return item, err
+	}
+	if item.Tags == nil {
+		item.Tags = []string{}
+	}
+	return item, nil
+}
+
+// itemFilter — фильтр объектов бронирования по параметрам запроса
+type itemFilter struct {
+	IDs         []string
+	Query       string
+	MinCapacity int
+	Category    string
+	Location    string
+	LocationID  string
+	Tags        []string
+	Attributes  map[string]string
+}
+
+// parseItemFilter читает фильтр из параметров item_id=a,b, q, min_capacity, category, location,
+// location_id (узел иерархии вместе с поддеревом), tag=a,b (нужны все метки) и attr.<ключ>=<значение>
+func parseItemFilter(r *http.Request) (itemFilter, error) {
+	query := r.URL.Query()
+	var f itemFilter
+	if v := query.Get("item_id"); v != "" {
+		f.IDs = strings.Split(v, ",")
+		for _, id := range f.IDs {
+			if _, err := uuid.Parse(id); err != nil {
+				return f, fmt.Errorf("invalid item_id %q", id)
+			}
+		}
+	}
+	f.Query = strings.TrimSpace(query.Get("q"))
+	if v := query.Get("min_capacity"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return f, fmt.Errorf("invalid min_capacity %q", v)
+		}
+		f.MinCapacity = n
+	}
+	f.Category = strings.TrimSpace(query.Get("category"))
+	f.Location = strings.TrimSpace(query.Get("location"))
+	if v := query.Get("location_id"); v != "" {
+		if _, err := uuid.Parse(v); err != nil {
+			return f, fmt.Errorf("invalid location_id %q", v)
+		}
+		f.LocationID = v
+	}
+	if v := query.Get("tag"); v != "" {
+		f.Tags = normalizeTags(strings.Split(v, ","))
+	}
+	for key, values := range query {
+		if !strings.HasPrefix(key, "attr.") || len(values) == 0 {
+			continue
+		}
+		name := strings.TrimPrefix(key, "attr.")
+		if name == "" {
+			return f, fmt.Errorf("invalid attribute filter %q", key)
+		}
+		if f.Attributes == nil {
+			f.Attributes = map[string]string{}
+		}
+		f.Attributes[name] = values[0]
+	}
+	return f, nil
+}
+
+// where возвращает условие на объект bi и его аргументы; параметры нумеруются начиная с next
+func (f itemFilter) where(next int) (string, []interface{}) {
+	conditions := []string{"TRUE"}
+	var args []interface{}
+	if len(f.IDs) > 0 {
+		conditions = append(conditions, fmt.Sprintf("bi.id = ANY($%d::uuid[])", next+len(args)))
+		args = append(args, pq.Array(f.IDs))
+	}
+	if f.Query != "" {
+		conditions = append(conditions, fmt.Sprintf(
+			"(bi.name ILIKE '%%' || $%[1]d || '%%' OR bi.description ILIKE '%%' || $%[1]d || '%%')", next+len(args)))
+		args = append(args, f.Query)
+	}
+	if f.MinCapacity > 0 {
+		conditions = append(conditions, fmt.Sprintf("COALESCE(bi.capacity, 1) >= $%d", next+len(args)))
+		args = append(args, f.MinCapacity)
+	}
+	if f.Category != "" {
+		conditions = append(conditions, fmt.Sprintf("lower(bi.category) = lower($%d)", next+len(args)))
+		args = append(args, f.Category)
+	}
+	if f.Location != "" {
+		conditions = append(conditions, fmt.Sprintf("bi.location ILIKE '%%' || $%d || '%%'", next+len(args)))
+		args = append(args, f.Location)
+	}
+	if f.LocationID != "" {
+		conditions = append(conditions, "bi.location_id IN ("+locationSubtreeSQL(next+len(args))+")")
+		args = append(args, f.LocationID)
+	}
+	if len(f.Tags) > 0 {
+		conditions = append(conditions, fmt.Sprintf("bi.tags @> $%d::text[]", next+len(args)))
+		args = append(args, pq.Array(f.Tags))
+	}
+
+	// Ключи сортируются, чтобы порядок параметров не зависел от обхода map
+	keys := make([]string, 0, len(f.Attributes))
+	for key := range f.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		conditions = append(conditions, fmt.Sprintf("bi.attributes ->> $%d = $%d", next+len(args), next+len(args)+1))
+		args = append(args, key, f.Attributes[key])
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// listBookingItems возвращает объекты, подходящие под фильтр, в порядке названия
+func listBookingItems(q queryer, f itemFilter) ([]models.BookingItem, error) {
+	condition, args := f.where(1)
+	rows, err := q.Query(`
+		SELECT `+bookingItemColumns+`
+		FROM booking_items bi
+		WHERE `+condition+`
+		ORDER BY bi.name
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.BookingItem{}
+	for rows.Next() {
+		item, err := scanBookingItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// normalizeTags убирает пробелы, пустые и повторяющиеся метки
+func normalizeTags(tags []string) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	return result
+}
+
+// itemInput — поля объекта в запросах создания и изменения; nil означает, что поле не передано
+type itemInput struct {
+	Name              *string                `json:"name"`
+	Description       *string                `json:"description"`
+	Capacity          *int                   `json:"capacity"`
+	Location          *string                `json:"location"`
+	Category          *string                `json:"category"`
+	LocationID        *string                `json:"location_id"`
+	Tags              []string               `json:"tags"`
+	Attributes        map[string]interface{} `json:"attributes"`
+	BufferBefore      *int                   `json:"buffer_before_minutes"`
+	BufferAfter       *int                   `json:"buffer_after_minutes"`
+	RequiresApproval  *bool                  `json:"requires_approval"`
+	PendingHoldsSeats *bool                  `json:"pending_holds_seats"`
+	TimeZone          *string                `json:"time_zone"`
+}
+
+// newBookingItem возвращает объект со значениями по умолчанию.
+// По умолчанию заявка, ожидающая подтверждения, занимает места
+func newBookingItem() models.BookingItem {
+	return models.BookingItem{
+		Capacity:          1,
+		Tags:              []string{},
+		Attributes:        map[string]interface{}{},
+		PendingHoldsSeats: true,
+	}
+}
+
+// apply переносит в item переданные поля
+func (in itemInput) apply(item *models.BookingItem) error {
+	if in.Name != nil {
+		item.Name = strings.TrimSpace(*in.Name)
+	}
+	if in.Description != nil {
+		item.Description = strings.TrimSpace(*in.Description)
+	}
+	if in.Capacity != nil {
+		item.Capacity = *in.Capacity
+	}
+	if in.Location != nil {
+		item.Location = strings.TrimSpace(*in.Location)
+	}
+	if in.Category != nil {
+		item.Category = strings.TrimSpace(*in.Category)
+	}
+	if in.LocationID != nil {
+		// Пустая строка отвязывает объект от узла иерархии
+		item.LocationID = nil
+		if *in.LocationID != "" {
+			id, err := uuid.Parse(*in.LocationID)
+			if err != nil {
+				return fmt.Errorf("Invalid location_id")
+			}
+			item.LocationID = &id
+		}
+	}
+	if in.Tags != nil {
+		item.Tags = normalizeTags(in.Tags)
+	}
+	if in.Attributes != nil {
+		item.Attributes = in.Attributes
+	}
+	if in.BufferBefore != nil {
+		item.BufferBefore = *in.BufferBefore
+	}
+	if in.BufferAfter != nil {
+		item.BufferAfter = *in.BufferAfter
+	}
+	if in.RequiresApproval != nil {
+		item.RequiresApproval = *in.RequiresApproval
+	}
+	if in.PendingHoldsSeats != nil {
+		item.PendingHoldsSeats = *in.PendingHoldsSeats
+	}
+	if in.TimeZone != nil {
+		// Пустая строка — пояс наследуется от узла иерархии
+		item.TimeZone = strings.TrimSpace(*in.TimeZone)
+	}
+	return nil
+}
+
+// validateBookingItem проверяет объект перед сохранением.
+// Значения атрибутов — только строки, числа и логические значения, чтобы по ним можно было фильтровать
+func validateBookingItem(item models.BookingItem) error {
+	if item.Name == "" {
+		return fmt.Errorf("Name is required")
+	}
+	if item.Capacity < 1 {
+		return fmt.Errorf("Capacity must be positive")
+	}
+	if item.BufferBefore < 0 || item.BufferAfter < 0 {
+		return fmt.Errorf("Buffers cannot be negative")
+	}
+	if item.BufferBefore+item.BufferAfter >= 24*60 {
+		return fmt.Errorf("Buffers must be shorter than a day")
+	}
+	if err := validateTimeZone(item.TimeZone); err != nil {
+		return err
+	}
+	for key, value := range item.Attributes {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("Attribute names cannot be empty")
+		}
+		switch value.(type) {
+		case string, float64, bool:
+		default:
+			return fmt.Errorf("Attribute %q must be a string, number or boolean", key)
+		}
+	}
+	return nil
+}
+
+// isUniqueViolation сообщает, нарушено ли ограничение уникальности
+func isUniqueViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23505"
+}
+
+// isForeignKeyViolation сообщает, ссылается ли запись на несуществующую или используемую строку
+func isForeignKeyViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23503"
+}
+
+// isExclusionViolation сообщает, нарушает ли запись ограничение исключения (например, пересечение слотов)
+func isExclusionViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23P01"
+}
+
+// insertBookingItem сохраняет новый объект и заполняет его ID
+func insertBookingItem(q queryer, item *models.BookingItem) error {
+	attributes, err := json.Marshal(item.Attributes)
+	if err != nil {
+		return err
+	}
+	return q.QueryRow(`
+		INSERT INTO booking_items (name, description, capacity, location, category, location_id, tags, attributes,
+		                           buffer_before_minutes, buffer_after_minutes, requires_approval, pending_holds_seats,
+		                           time_zone)
+		VALUES ($1, NULLIF($2, ''), $3, NULLIF($4, ''), NULLIF($5, ''), $6, $7, $8, $9, $10, $11, $12, NULLIF($13, ''))
+		RETURNING id, item_time_zone(id)
+	`, item.Name, item.Description, item.Capacity, item.Location, item.Category, item.LocationID,
+		pq.Array(item.Tags), attributes, item.BufferBefore, item.BufferAfter,
+		item.RequiresApproval, item.PendingHoldsSeats, item.TimeZone).Scan(&item.ID, &item.EffectiveTimeZone)
+}
+
+// updateBookingItem перезаписывает все поля объекта.
+// Новая вместимость применяется к слотам, созданным после изменения
+func updateBookingItem(tx *sql.Tx, item models.BookingItem) error {
+	attributes, err := json.Marshal(item.Attributes)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		UPDATE booking_items
+		SET name = $2, description = NULLIF($3, ''), capacity = $4, location = NULLIF($5, ''),
+		    category = NULLIF($6, ''), location_id = $7, tags = $8, attributes = $9,
+		    buffer_before_minutes = $10, buffer_after_minutes = $11,
+		    requires_approval = $12, pending_holds_seats = $13, time_zone = NULLIF($14, ''), updated_at = NOW()
+		WHERE id = $1
+	`, item.ID, item.Name, item.Description, item.Capacity, item.Location, item.Category, item.LocationID,
+		pq.Array(item.Tags), attributes, item.BufferBefore, item.BufferAfter,
+		item.RequiresApproval, item.PendingHoldsSeats, item.TimeZone)
+	return err
+}
+
+func ApiListBookingItemsHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseItemFilter(r)
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	items, err := listBookingItems(models.DB, filter)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, items)
+}
+
+func ApiGetBookingItemHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	itemID := vars["id"]
+	if _, err := uuid.Parse(itemID); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid item ID"})
+		return
+	}
+
+	item, err := scanBookingItem(models.DB.QueryRow(`
+		SELECT `+bookingItemColumns+` FROM booking_items bi WHERE bi.id = $1
+	`, itemID))
+	if err == sql.ErrNoRows {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Item not found"})
+		return
+	}
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, item)
+}
+
+// ApiReplaceBookingItemHandler (PUT) заменяет объект целиком: непереданные поля получают значения по умолчанию
+func ApiReplaceBookingItemHandler(w http.ResponseWriter, r *http.Request) {
+	saveBookingItem(w, r, false)
+}
+
+// ApiPatchBookingItemHandler (PATCH) меняет только переданные поля объекта
+func ApiPatchBookingItemHandler(w http.ResponseWriter, r *http.Request) {
+	saveBookingItem(w, r, true)
+}
+
+func saveBookingItem(w http.ResponseWriter, r *http.Request, partial bool) {
+	session, _ := models.Store.Get(r, "session")
+	role, ok := session.Values["role"].(string)
+	if !ok || role != "admin" {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	vars := mux.Vars(r)
+	itemID := vars["id"]
+	if _, err := uuid.Parse(itemID); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid item ID"})
+		return
+	}
+
+	var input itemInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	current, err := scanBookingItem(tx.QueryRow(`
+		SELECT `+bookingItemColumns+` FROM booking_items bi WHERE bi.id = $1 FOR UPDATE
+	`, itemID))
+	if err == sql.ErrNoRows {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Item not found"})
+		return
+	}
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	item := current
+	if !partial {
+		item = newBookingItem()
+		item.ID = current.ID
+	}
+	if err := input.apply(&item); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := validateBookingItem(item); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := updateBookingItem(tx, item); err != nil {
+		if isUniqueViolation(err) {
+			respondWithJSON(w, http.StatusConflict, map[string]string{"error": "Item with this name already exists"})
+			return
+		}
+		if isForeignKeyViolation(err) {
+			respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Location not found"})
+			return
+		}
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := tx.QueryRow("SELECT item_time_zone($1)", item.ID).Scan(&item.EffectiveTimeZone); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, item)
+}
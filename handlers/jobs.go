@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"log"
+	"time"
+)
+
+// StartBackgroundJobs запускает фоновые задачи приложения
+func StartBackgroundJobs() {
+	go runPeriodically("slot generation", 24*time.Hour, generateRollingSlots)
+	go runPeriodically("waitlist expiry", time.Minute, expireWaitlist)
+	go runPeriodically("hold expiry", time.Minute, expireHolds)
+	go runPeriodically("booking completion", 5*time.Minute, completePastBookings)
+}
+
+// runPeriodically выполняет job сразу и затем каждые interval
+func runPeriodically(name string, interval time.Duration, job func() error) {
+	for {
+		if err := job(); err != nil {
+			log.Printf("Background job %q failed: %v", name, err)
+		}
+		time.Sleep(interval)
+	}
+}
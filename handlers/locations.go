@@ -0,0 +1,603 @@
+package handlers
+
+import (
+	"booking-system/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// locationKinds — допустимые типы узлов иерархии
+var locationKinds = map[string]bool{
+	"site":     true,
+	"building": true,
+	"floor":    true,
+	"zone":     true,
+	"category": true,
+}
+
+// locationSubtreeSQL возвращает подзапрос с узлом из параметра arg и всеми его потомками
+func locationSubtreeSQL(arg int) string {
+	return fmt.Sprintf("SELECT location_subtree($%d::uuid)", arg)
+}
+
+// managedLocationsSQL возвращает подзапрос с узлами, за которые отвечает менеджер из параметра arg,
+// вместе с их поддеревьями
+func managedLocationsSQL(arg int) string {
+	return fmt.Sprintf("SELECT location_subtree(lm.location_id) FROM location_managers lm WHERE lm.user_id = $%d::uuid", arg)
+}
+
+// loadLocationTree загружает всю иерархию и возвращает корневые узлы, дети упорядочены по имени
+func loadLocationTree(q queryer) ([]*models.Location, error) {
+	rows, err := q.Query(`
+		SELECT l.id, l.parent_id, l.name, l.kind, COALESCE(l.time_zone, ''),
+		       COALESCE(ARRAY(SELECT lm.user_id::text FROM location_managers lm WHERE lm.location_id = l.id), '{}')
+		FROM locations l
+		ORDER BY l.name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []*models.Location
+	byID := make(map[uuid.UUID]*models.Location)
+	for rows.Next() {
+		l := &models.Location{Children: []*models.Location{}}
+		if err := rows.Scan(&l.ID, &l.ParentID, &l.Name, &l.Kind, &l.TimeZone, pq.Array(&l.ManagerIDs)); err != nil {
+			return nil, err
+		}
+		if l.ManagerIDs == nil {
+			l.ManagerIDs = []string{}
+		}
+		all = append(all, l)
+		byID[l.ID] = l
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	roots := []*models.Location{}
+	for _, l := range all {
+		if l.ParentID == nil {
+			roots = append(roots, l)
+			continue
+		}
+		if parent, ok := byID[*l.ParentID]; ok {
+			parent.Children = append(parent.Children, l)
+		}
+	}
+	return roots, nil
+}
+
+// locationOption — узел иерархии для выпадающих списков: полный путь вместо вложенности
+type locationOption struct {
+	ID         uuid.UUID
+	Kind       string
+	Label      string
+	ManagerIDs string
+}
+
+// flattenLocations обходит дерево в глубину и подписывает узлы путём от корня
+func flattenLocations(nodes []*models.Location, prefix string) []locationOption {
+	var options []locationOption
+	for _, node := range nodes {
+		label := node.Name
+		if prefix != "" {
+			label = prefix + " / " + node.Name
+		}
+		options = append(options, locationOption{
+			ID:         node.ID,
+			Kind:       node.Kind,
+			Label:      label,
+			ManagerIDs: strings.Join(node.ManagerIDs, ","),
+		})
+		options = append(options, flattenLocations(node.Children, label)...)
+	}
+	return options
+}
+
+// canManageLocation сообщает, может ли пользователь менять настройки узла:
+// администратор — любого, менеджер — только закреплённого за ним поддерева
+func canManageLocation(q queryer, role, userID, locationID string) (bool, error) {
+	if role == "admin" {
+		return true, nil
+	}
+	if role != "manager" {
+		return false, nil
+	}
+	var allowed bool
+	err := q.QueryRow(`
+		SELECT $2::uuid IN (`+managedLocationsSQL(1)+`)
+	`, userID, locationID).Scan(&allowed)
+	return allowed, err
+}
+
+func ApiGetLocationsHandler(w http.ResponseWriter, r *http.Request) {
+	tree, err := loadLocationTree(models.DB)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, tree)
+}
+
+func ApiCreateLocationHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	role, ok := session.Values["role"].(string)
+	if !ok || role != "admin" {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		Name     string     `json:"name"`
+		Kind     string     `json:"kind"`
+		ParentID *uuid.UUID `json:"parent_id"`
+		TimeZone string     `json:"time_zone"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Name is required"})
+		return
+	}
+	if req.Kind == "" {
+		req.Kind = "zone"
+	}
+	if !locationKinds[req.Kind] {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Kind must be one of site, building, floor, zone, category"})
+		return
+	}
+	req.TimeZone = strings.TrimSpace(req.TimeZone)
+	if err := validateTimeZone(req.TimeZone); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	location := models.Location{
+		ParentID:   req.ParentID,
+		Name:       req.Name,
+		Kind:       req.Kind,
+		TimeZone:   req.TimeZone,
+		ManagerIDs: []string{},
+		Children:   []*models.Location{},
+	}
+	err := models.DB.QueryRow(`
+		INSERT INTO locations (parent_id, name, kind, time_zone) VALUES ($1, $2, $3, NULLIF($4, '')) RETURNING id
+	`, req.ParentID, req.Name, req.Kind, req.TimeZone).Scan(&location.ID)
+	if isForeignKeyViolation(err) {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Parent location not found"})
+		return
+	}
+	if isUniqueViolation(err) {
+		respondWithJSON(w, http.StatusConflict, map[string]string{"error": "Location with this name already exists here"})
+		return
+	}
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, location)
+}
+
+// ApiUpdateLocationHandler меняет имя, тип, часовой пояс или родителя узла.
+// parent_id = "" переносит узел в корень; перенос узла внутрь собственного поддерева запрещён.
+// time_zone = "" снимает собственный пояс узла, он наследуется от родителя
+func ApiUpdateLocationHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	role, ok := session.Values["role"].(string)
+	if !ok || role != "admin" {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	vars := mux.Vars(r)
+	locationID := vars["id"]
+
+	var req struct {
+		Name     *string `json:"name"`
+		Kind     *string `json:"kind"`
+		ParentID *string `json:"parent_id"`
+		TimeZone *string `json:"time_zone"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	// Блокировка всей таблицы исключает одновременные переносы, образующие цикл
+	if _, err := tx.Exec("LOCK TABLE locations IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	var location models.Location
+	err = tx.QueryRow(`
+		SELECT id, parent_id, name, kind, COALESCE(time_zone, '') FROM locations WHERE id = $1
+	`, locationID).Scan(&location.ID, &location.ParentID, &location.Name, &location.Kind, &location.TimeZone)
+	if err == sql.ErrNoRows {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Location not found"})
+		return
+	}
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if req.Name != nil {
+		location.Name = strings.TrimSpace(*req.Name)
+		if location.Name == "" {
+			respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Name is required"})
+			return
+		}
+	}
+	if req.Kind != nil {
+		if !locationKinds[*req.Kind] {
+			respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Kind must be one of site, building, floor, zone, category"})
+			return
+		}
+		location.Kind = *req.Kind
+	}
+	if req.TimeZone != nil {
+		location.TimeZone = strings.TrimSpace(*req.TimeZone)
+		if err := validateTimeZone(location.TimeZone); err != nil {
+			respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	if req.ParentID != nil {
+		location.ParentID = nil
+		if *req.ParentID != "" {
+			parentID, err := uuid.Parse(*req.ParentID)
+			if err != nil {
+				respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid parent_id"})
+				return
+			}
+
+			var cycle bool
+			err = tx.QueryRow(`SELECT $2::uuid IN (`+locationSubtreeSQL(1)+`)`, locationID, parentID).Scan(&cycle)
+			if err != nil {
+				respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+			if cycle {
+				respondWithJSON(w, http.StatusConflict, map[string]string{"error": "Location cannot be moved into its own subtree"})
+				return
+			}
+			location.ParentID = &parentID
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE locations SET name = $2, kind = $3, parent_id = $4, time_zone = NULLIF($5, ''), updated_at = NOW()
+		WHERE id = $1
+	`, locationID, location.Name, location.Kind, location.ParentID, location.TimeZone)
+	if isForeignKeyViolation(err) {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Parent location not found"})
+		return
+	}
+	if isUniqueViolation(err) {
+		respondWithJSON(w, http.StatusConflict, map[string]string{"error": "Location with this name already exists here"})
+		return
+	}
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"id":        location.ID,
+		"parent_id": location.ParentID,
+		"name":      location.Name,
+		"kind":      location.Kind,
+		"time_zone": location.TimeZone,
+	})
+}
+
+// ApiDeleteLocationHandler удаляет пустой узел: дочерние узлы и объекты нужно сначала перенести
+func ApiDeleteLocationHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	role, ok := session.Values["role"].(string)
+	if !ok || role != "admin" {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	vars := mux.Vars(r)
+	locationID := vars["id"]
+
+	result, err := models.DB.Exec("DELETE FROM locations WHERE id = $1", locationID)
+	if isForeignKeyViolation(err) {
+		respondWithJSON(w, http.StatusConflict, map[string]string{"error": "Location still has child locations or items"})
+		return
+	}
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Location not found"})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func ApiGetLocationHoursHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	locationID := vars["id"]
+
+	var exists bool
+	err := models.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM locations WHERE id = $1)", locationID).Scan(&exists)
+	if err != nil || !exists {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Location not found"})
+		return
+	}
+
+	rows, err := models.DB.Query(`
+		SELECT weekday, to_char(open_time, 'HH24:MI'), to_char(close_time, 'HH24:MI')
+		FROM location_hours
+		WHERE location_id = $1
+		ORDER BY weekday, open_time
+	`, locationID)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	days := []*models.LocationHours{}
+	for rows.Next() {
+		var weekday int
+		var interval models.TimeInterval
+		if err := rows.Scan(&weekday, &interval.Start, &interval.End); err != nil {
+			respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if n := len(days); n == 0 || days[n-1].Weekday != weekday {
+			days = append(days, &models.LocationHours{Weekday: weekday})
+		}
+		days[len(days)-1].Open = append(days[len(days)-1].Open, interval)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"location_id":  locationID,
+		"unrestricted": len(days) == 0,
+		"days":         days,
+	})
+}
+
+// ApiUpdateLocationHoursHandler заменяет часы работы узла; дни недели, не переданные в запросе, становятся выходными
+func ApiUpdateLocationHoursHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	role, _ := session.Values["role"].(string)
+	userID, _ := session.Values["user_id"].(string)
+
+	vars := mux.Vars(r)
+	locationID := vars["id"]
+
+	allowed, err := canManageLocation(models.DB, role, userID, locationID)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if !allowed {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		Days []models.LocationHours `json:"days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	if len(req.Days) == 0 {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "At least one day is required; use DELETE to remove opening hours"})
+		return
+	}
+
+	seen := make(map[int]bool)
+	for _, day := range req.Days {
+		if err := validateDaySchedule(models.DaySchedule{Weekday: day.Weekday, Open: day.Open}); err != nil {
+			respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if seen[day.Weekday] {
+			respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Weekday %d is listed twice", day.Weekday)})
+			return
+		}
+		seen[day.Weekday] = true
+	}
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	var lockedID string
+	err = tx.QueryRow("SELECT id FROM locations WHERE id = $1 FOR UPDATE", locationID).Scan(&lockedID)
+	if err == sql.ErrNoRows {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Location not found"})
+		return
+	}
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if _, err := tx.Exec("DELETE FROM location_hours WHERE location_id = $1", locationID); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	for _, day := range req.Days {
+		for _, interval := range day.Open {
+			_, err := tx.Exec(`
+				INSERT INTO location_hours (location_id, weekday, open_time, close_time)
+				VALUES ($1, $2, $3, $4)
+			`, locationID, day.Weekday, interval.Start, interval.End)
+			if err != nil {
+				respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+func ApiDeleteLocationHoursHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	role, _ := session.Values["role"].(string)
+	userID, _ := session.Values["user_id"].(string)
+
+	vars := mux.Vars(r)
+	locationID := vars["id"]
+
+	allowed, err := canManageLocation(models.DB, role, userID, locationID)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if !allowed {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	if _, err := models.DB.Exec("DELETE FROM location_hours WHERE location_id = $1", locationID); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ApiUpdateLocationManagersHandler заменяет список менеджеров, ответственных за узел
+func ApiUpdateLocationManagersHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	role, ok := session.Values["role"].(string)
+	if !ok || role != "admin" {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	vars := mux.Vars(r)
+	locationID := vars["id"]
+
+	var req struct {
+		ManagerIDs []string `json:"manager_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	for _, id := range req.ManagerIDs {
+		if _, err := uuid.Parse(id); err != nil {
+			respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Invalid manager id %q", id)})
+			return
+		}
+	}
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	var lockedID string
+	err = tx.QueryRow("SELECT id FROM locations WHERE id = $1 FOR UPDATE", locationID).Scan(&lockedID)
+	if err == sql.ErrNoRows {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Location not found"})
+		return
+	}
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	var managers int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM users WHERE id = ANY($1::uuid[]) AND role = 'manager'
+	`, pq.Array(req.ManagerIDs)).Scan(&managers)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if managers != len(normalizeIDs(req.ManagerIDs)) {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "All assigned users must be managers"})
+		return
+	}
+
+	if _, err := tx.Exec("DELETE FROM location_managers WHERE location_id = $1", locationID); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	_, err = tx.Exec(`
+		INSERT INTO location_managers (location_id, user_id)
+		SELECT $1, unnest($2::uuid[])
+		ON CONFLICT DO NOTHING
+	`, locationID, pq.Array(req.ManagerIDs))
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"location_id": locationID,
+		"manager_ids": normalizeIDs(req.ManagerIDs),
+	})
+}
+
+// normalizeIDs убирает повторяющиеся идентификаторы, сохраняя порядок
+func normalizeIDs(ids []string) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, id := range ids {
+		id = strings.ToLower(id)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		result = append(result, id)
+	}
+	return result
+}
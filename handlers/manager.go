@@ -2,10 +2,15 @@ package handlers
 
 import (
 	"booking-system/models"
+	"database/sql"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/lib/pq"
 )
 
 func ManagerHandler(w http.ResponseWriter, r *http.Request) {
@@ -32,23 +37,22 @@ func ManagerHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get booking items
-	rows, err = models.DB.Query("SELECT id, name FROM booking_items")
+	items, err := listBookingItems(models.DB, itemFilter{})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
-	var items []models.BookingItem
-	for rows.Next() {
-		var i models.BookingItem
-		rows.Scan(&i.ID, &i.Name)
-		items = append(items, i)
+	locations, err := loadLocationTree(models.DB)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	models.Tmpl.ExecuteTemplate(w, "manager.html", map[string]interface{}{
-		"Users": users,
-		"Items": items,
+		"Users":     users,
+		"Items":     items,
+		"Locations": flattenLocations(locations, ""),
 	})
 }
 
@@ -63,7 +67,13 @@ func ApiGetItemSlotsHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	itemID := vars["id"]
 
-	rows, err := models.DB.Query("SELECT id, item_id, date, start_time, end_time, is_available FROM booking_slots WHERE item_id = $1 ORDER BY date, start_time", itemID)
+	rows, err := models.DB.Query(`
+		SELECT bs.id, bs.item_id, bs.date, bs.start_time, bs.end_time, bs.is_available,
+		       bs.max_participants, `+remainingSeatsSQL+`, bs.starts_at, bs.ends_at, item_time_zone(bs.item_id)
+		FROM booking_slots bs
+		WHERE bs.item_id = $1 AND bs.removed_at IS NULL
+		ORDER BY bs.date, bs.start_time
+	`, itemID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -73,111 +83,267 @@ func ApiGetItemSlotsHandler(w http.ResponseWriter, r *http.Request) {
 	var slots []models.BookingSlot
 	for rows.Next() {
 		var s models.BookingSlot
-		rows.Scan(&s.ID, &s.ItemID, &s.Date, &s.StartTime, &s.EndTime, &s.IsAvailable)
+		var startsAt, endsAt time.Time
+		var zone string
+		rows.Scan(&s.ID, &s.ItemID, &s.Date, &s.StartTime, &s.EndTime, &s.IsAvailable,
+			&s.MaxParticipants, &s.RemainingSeats, &startsAt, &endsAt, &zone)
+		setSlotInstants(&s, startsAt, endsAt, zone)
 		slots = append(slots, s)
 	}
 
 	json.NewEncoder(w).Encode(slots)
 }
 
+// ApiUpdateItemSlotsHandler приводит слоты объекта к присланному списку, изменяя только отличающиеся слоты.
+// Удаление слотов с активными бронированиями требует confirm_delete=true; dry_run=true возвращает план без изменений
 func ApiUpdateItemSlotsHandler(w http.ResponseWriter, r *http.Request) {
 	session, _ := models.Store.Get(r, "session")
 	role, ok := session.Values["role"].(string)
 	if !ok || (role != "admin" && role != "manager") {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
 		return
 	}
 
 	vars := mux.Vars(r)
 	itemID := vars["id"]
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	confirmDelete := r.URL.Query().Get("confirm_delete") == "true"
 
 	var slots []models.BookingSlot
 	if err := json.NewDecoder(r.Body).Decode(&slots); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
 		return
 	}
 
-	// Start transaction
 	tx, err := models.DB.Begin()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
+	defer tx.Rollback()
 
-	// Delete old slots
-	_, err = tx.Exec("DELETE FROM booking_slots WHERE item_id = $1", itemID)
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM booking_items WHERE id::text = $1)", itemID).Scan(&exists); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if !exists {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Item not found"})
+		return
+	}
+
+	plan, err := planItemSlots(tx, itemID, slots)
 	if err != nil {
-		tx.Rollback()
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondBookingError(w, err)
+		return
+	}
+	plan.DryRun = dryRun
+	if dryRun {
+		respondWithJSON(w, http.StatusOK, plan)
+		return
+	}
+	if len(plan.AffectedBookings) > 0 && !confirmDelete {
+		respondWithJSON(w, http.StatusConflict, map[string]interface{}{
+			"error": fmt.Sprintf("%d active bookings would be cancelled; repeat with confirm_delete=true", len(plan.AffectedBookings)),
+			"plan":  plan,
+		})
 		return
 	}
 
-	// Insert new slots
-	for _, slot := range slots {
-		_, err = tx.Exec("INSERT INTO booking_slots (item_id, date, start_time, end_time, is_available) VALUES ($1, $2, $3, $4, $5)",
-			itemID, slot.Date, slot.StartTime, slot.EndTime, slot.IsAvailable)
-		if err != nil {
-			tx.Rollback()
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+	if err := applyItemSlots(tx, itemID, &plan); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
 	}
 
-	// Commit transaction
 	err = tx.Commit()
+	if isExclusionViolation(err) {
+		// Параллельно созданный слот пересёкся с новыми
+		respondWithJSON(w, http.StatusConflict, map[string]string{"error": "Slots overlap with other slots of the same item"})
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	respondWithJSON(w, http.StatusOK, plan)
 }
 
 func ApiCreateSlotHandler(w http.ResponseWriter, r *http.Request) {
 	session, _ := models.Store.Get(r, "session")
 	role, ok := session.Values["role"].(string)
 	if !ok || (role != "admin" && role != "manager") {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
 		return
 	}
 
 	var slot models.BookingSlot
 	if err := json.NewDecoder(r.Body).Decode(&slot); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	if err := normalizeSlot(&slot); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
 
-	err := models.DB.QueryRow(
-		"INSERT INTO booking_slots (item_id, date, start_time, end_time, is_available) VALUES ($1, $2, $3, $4, $5) RETURNING id",
-		slot.ItemID, slot.Date, slot.StartTime, slot.EndTime, slot.IsAvailable,
-	).Scan(&slot.ID)
+	overlaps, err := findSlotOverlaps(models.DB, slot)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if len(overlaps) > 0 {
+		respondBookingError(w, &slotOverlapError{Overlaps: overlaps})
+		return
+	}
 
+	err = models.DB.QueryRow(`
+		INSERT INTO booking_slots (item_id, date, start_time, end_time, is_available, max_participants)
+		VALUES ($1, $2, $3, $4, $5, `+slotCapacitySQL(1, 6)+`)
+		RETURNING id, max_participants
+	`, slot.ItemID, slot.Date, slot.StartTime, slot.EndTime, slot.IsAvailable, slot.MaxParticipants,
+	).Scan(&slot.ID, &slot.MaxParticipants)
+	if isExclusionViolation(err) {
+		respondWithJSON(w, http.StatusConflict, map[string]string{"error": "Slots overlap with other slots of the same item"})
+		return
+	}
+	if isForeignKeyViolation(err) {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Item not found"})
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
+	slot.RemainingSeats = slot.MaxParticipants
 
-	json.NewEncoder(w).Encode(slot)
+	respondWithJSON(w, http.StatusOK, slot)
 }
 
+// ApiGetSlotOverlapsHandler находит уже существующие пересекающиеся слоты, чтобы их можно было исправить.
+// Пока они есть, ограничение booking_slots_no_overlap не создаётся
+func ApiGetSlotOverlapsHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	role, ok := session.Values["role"].(string)
+	if !ok || (role != "admin" && role != "manager") {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	rows, err := models.DB.Query(`
+		SELECT a.item_id, bi.name, to_char(a.date, 'YYYY-MM-DD'),
+		       a.id, a.start_time::text, a.end_time::text, a.is_available, a.max_participants,
+		       b.id, b.start_time::text, b.end_time::text, b.is_available, b.max_participants
+		FROM booking_slots a
+		JOIN booking_slots b ON a.item_id = b.item_id AND a.date = b.date
+		 AND (a.start_time, a.id) < (b.start_time, b.id)
+		 AND a.start_time < b.end_time AND a.end_time > b.start_time
+		JOIN booking_items bi ON a.item_id = bi.id
+		WHERE ($1 = '' OR a.item_id::text = $1) AND a.removed_at IS NULL AND b.removed_at IS NULL
+		ORDER BY bi.name, a.date, a.start_time, b.start_time
+	`, r.URL.Query().Get("item_id"))
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	overlaps := []models.SlotOverlap{}
+	for rows.Next() {
+		var o models.SlotOverlap
+		if err := rows.Scan(&o.ItemID, &o.ItemName, &o.Date,
+			&o.First.ID, &o.First.StartTime, &o.First.EndTime, &o.First.IsAvailable, &o.First.MaxParticipants,
+			&o.Second.ID, &o.Second.StartTime, &o.Second.EndTime, &o.Second.IsAvailable, &o.Second.MaxParticipants,
+		); err != nil {
+			respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		o.First.ItemID, o.Second.ItemID = o.ItemID, o.ItemID
+		o.First.Date, o.Second.Date = o.Date, o.Date
+		overlaps = append(overlaps, o)
+	}
+
+	respondWithJSON(w, http.StatusOK, overlaps)
+}
+
+// ApiDeleteSlotHandler удаляет слот. Слот с бронированиями снимается из расписания с сохранением истории,
+// а если среди них есть активные, их отмена требует confirm_delete=true
 func ApiDeleteSlotHandler(w http.ResponseWriter, r *http.Request) {
 	session, _ := models.Store.Get(r, "session")
 	role, ok := session.Values["role"].(string)
 	if !ok || (role != "admin" && role != "manager") {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
 		return
 	}
 
 	vars := mux.Vars(r)
 	slotID := vars["id"]
+	confirmDelete := r.URL.Query().Get("confirm_delete") == "true"
+	if _, err := uuid.Parse(slotID); err != nil {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Slot not found"})
+		return
+	}
 
-	_, err := models.DB.Exec("DELETE FROM booking_slots WHERE id = $1", slotID)
+	tx, err := models.DB.Begin()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
+	defer tx.Rollback()
 
-	w.WriteHeader(http.StatusOK)
+	var slot models.BookingSlot
+	var hasBookings bool
+	err = tx.QueryRow(`
+		SELECT bs.id, bs.item_id, to_char(bs.date, 'YYYY-MM-DD'), bs.start_time::text, bs.end_time::text,
+		       bs.is_available, bs.max_participants,
+		       EXISTS (SELECT 1 FROM bookings b WHERE `+bookingCoversSlotSQL+`)
+		FROM booking_slots bs
+		WHERE bs.id = $1 AND bs.removed_at IS NULL
+		FOR UPDATE OF bs
+	`, slotID).Scan(&slot.ID, &slot.ItemID, &slot.Date, &slot.StartTime, &slot.EndTime,
+		&slot.IsAvailable, &slot.MaxParticipants, &hasBookings)
+	if err == sql.ErrNoRows {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Slot not found"})
+		return
+	}
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	plan := models.SlotChangePlan{
+		Created:  []models.BookingSlot{},
+		Updated:  []models.BookingSlot{},
+		Deleted:  []models.BookingSlot{},
+		Archived: []models.BookingSlot{},
+	}
+	if hasBookings {
+		plan.Archived = append(plan.Archived, slot)
+	} else {
+		plan.Deleted = append(plan.Deleted, slot)
+	}
+	if plan.AffectedBookings, err = loadAffectedBookings(tx, plan.Archived); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if len(plan.AffectedBookings) > 0 && !confirmDelete {
+		respondWithJSON(w, http.StatusConflict, map[string]interface{}{
+			"error": fmt.Sprintf("%d active bookings would be cancelled; repeat with confirm_delete=true", len(plan.AffectedBookings)),
+			"plan":  plan,
+		})
+		return
+	}
+
+	if err := removeSlots(tx, &plan); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, plan)
 }
 
 func ApiBlockSlotHandler(w http.ResponseWriter, r *http.Request) {
@@ -228,3 +394,200 @@ func ApiToggleDateAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusOK)
 }
+
+func ApiManagerListBookingsHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	role, ok := session.Values["role"].(string)
+	if !ok || (role != "admin" && role != "manager") {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	statuses, err := parseStatusFilter(r)
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	query := r.URL.Query()
+	var filters [5]interface{}
+	for i, name := range []string{"item_id", "user_id", "from", "to", "location_id"} {
+		if v := query.Get(name); v != "" {
+			filters[i] = v
+		}
+	}
+
+	// mine=true оставляет только объекты из узлов иерархии, закреплённых за текущим менеджером
+	var managerID interface{}
+	if query.Get("mine") == "true" {
+		managerID, _ = session.Values["user_id"].(string)
+	}
+
+	rows, err := models.DB.Query(`
+		SELECT b.id, b.user_id, u.login, u.full_name, bi.id, bi.name, bs.id,
+		       to_char(bs.date, 'YYYY-MM-DD'), bs.start_time::text, `+bookingEndTimeSQL+`,
+		       b.participants, b.status, b.cancelled_at, b.cancel_reason, b.reject_reason,
+		       b.checked_in_at, b.created_at
+		FROM bookings b
+		JOIN booking_slots bs ON b.slot_id = bs.id
+		JOIN booking_items bi ON bs.item_id = bi.id
+		JOIN users u ON b.user_id = u.id
+		WHERE ($1::uuid IS NULL OR bi.id = $1::uuid)
+		  AND ($2::uuid IS NULL OR b.user_id = $2::uuid)
+		  AND ($3::date IS NULL OR bs.date >= $3::date)
+		  AND ($4::date IS NULL OR bs.date <= $4::date)
+		  AND ($5::text[] IS NULL OR b.status = ANY($5))
+		  AND ($6::uuid IS NULL OR bi.location_id IN (`+locationSubtreeSQL(6)+`))
+		  AND ($7::uuid IS NULL OR bi.location_id IN (`+managedLocationsSQL(7)+`))
+		ORDER BY bs.date, bs.start_time, bi.name
+		LIMIT 500
+	`, filters[0], filters[1], filters[2], filters[3], pq.Array(statuses), filters[4], managerID)
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	bookings := []models.BookingDetails{}
+	for rows.Next() {
+		var b models.BookingDetails
+		rows.Scan(&b.ID, &b.UserID, &b.UserLogin, &b.UserFullName, &b.ItemID, &b.ItemName, &b.SlotID,
+			&b.Date, &b.StartTime, &b.EndTime, &b.Participants, &b.Status, &b.CancelledAt, &b.CancelReason,
+			&b.RejectReason, &b.CheckedInAt, &b.CreatedAt)
+		bookings = append(bookings, b)
+	}
+
+	respondWithJSON(w, http.StatusOK, bookings)
+}
+
+func ApiManagerCreateBookingHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	role, ok := session.Values["role"].(string)
+	if !ok || (role != "admin" && role != "manager") {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+	actorID, _ := session.Values["user_id"].(string)
+
+	var req struct {
+		UserID       string `json:"user_id"`
+		SlotID       string `json:"slot_id"`
+		Participants int    `json:"participants"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	if req.UserID == "" || req.SlotID == "" {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "user_id and slot_id are required"})
+		return
+	}
+	if req.Participants == 0 {
+		req.Participants = 1
+	}
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", req.UserID).Scan(&exists)
+	if err != nil || !exists {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+		return
+	}
+
+	// Бронирование от имени пользователя подчиняется тем же правилам, что и самостоятельное
+	bookingID, err := bookSlot(tx, req.UserID, req.SlotID, req.Participants)
+	if err != nil {
+		respondBookingError(w, err)
+		return
+	}
+
+	// Бронирование, оформленное менеджером, не нуждается в отдельном подтверждении
+	_, err = tx.Exec("UPDATE bookings SET status = 'confirmed', updated_at = NOW() WHERE id = $1 AND status = 'pending'", bookingID)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := logBookingEvent(tx, bookingID, "created_by_manager", nil, &req.SlotID, actorID); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]string{"id": bookingID})
+}
+
+func ApiManagerCancelBookingHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	role, ok := session.Values["role"].(string)
+	if !ok || (role != "admin" && role != "manager") {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+	actorID, _ := session.Values["user_id"].(string)
+
+	vars := mux.Vars(r)
+	bookingID := vars["id"]
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Reason == "" {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Cancellation reason is required"})
+		return
+	}
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	var userID, itemName, date, startTime string
+	err = tx.QueryRow(`
+		SELECT b.user_id, bi.name, to_char(bs.date, 'YYYY-MM-DD'), bs.start_time::text
+		FROM bookings b
+		JOIN booking_slots bs ON b.slot_id = bs.id
+		JOIN booking_items bi ON bs.item_id = bi.id
+		WHERE b.id = $1
+		FOR UPDATE OF b
+	`, bookingID).Scan(&userID, &itemName, &date, &startTime)
+	if err != nil {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Booking not found"})
+		return
+	}
+
+	if err := cancelBooking(tx, bookingID, req.Reason); err != nil {
+		respondBookingError(w, err)
+		return
+	}
+
+	if err := logBookingEvent(tx, bookingID, "cancelled_by_manager", nil, nil, actorID); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	message := fmt.Sprintf("Your booking of %s on %s at %s was cancelled: %s", itemName, date, startTime, req.Reason)
+	if err := notifyUser(tx, userID, message); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"booking-system/models"
+	"database/sql"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// execer — общий интерфейс для *sql.DB и *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// notifyUser сохраняет уведомление для пользователя
+func notifyUser(e execer, userID, message string) error {
+	_, err := e.Exec("INSERT INTO notifications (user_id, message) VALUES ($1, $2)", userID, message)
+	return err
+}
+
+// loadNotifications возвращает последние уведомления пользователя
+func loadNotifications(userID string, unreadOnly bool) ([]models.Notification, error) {
+	rows, err := models.DB.Query(`
+		SELECT id, message, created_at, read_at IS NOT NULL
+		FROM notifications
+		WHERE user_id = $1 AND (NOT $2 OR read_at IS NULL)
+		ORDER BY created_at DESC
+		LIMIT 50
+	`, userID, unreadOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		var n models.Notification
+		rows.Scan(&n.ID, &n.Message, &n.CreatedAt, &n.Read)
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+func ApiGetNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	userID, ok := session.Values["user_id"].(string)
+	if !ok {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	notifications, err := loadNotifications(userID, r.URL.Query().Get("unread") == "true")
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, notifications)
+}
+
+func ApiMarkNotificationReadHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	userID, ok := session.Values["user_id"].(string)
+	if !ok {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	vars := mux.Vars(r)
+	notificationID := vars["id"]
+
+	_, err := models.DB.Exec(`
+		UPDATE notifications SET read_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND read_at IS NULL
+	`, notificationID, userID)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"booking-system/models"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Коды нарушений правил бронирования
+const (
+	ViolationSlotInPast    = "slot_in_past"
+	ViolationBookingWindow = "booking_window_exceeded"
+	ViolationMinLeadTime   = "min_lead_time"
+	ViolationDailyLimit    = "daily_limit_reached"
+	ViolationNoShows       = "no_show_suspension"
+)
+
+// queryer — общий интерфейс для *sql.DB и *sql.Tx
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// checkBookingPolicy проверяет бронирование пользователем слота slotID на соответствие настройкам
+// system_settings и возвращает все нарушенные правила. Сроки считаются от начала слота,
+// дневной лимит — по дате слота в поясе его объекта. excludeBookingID (если задан) не учитывается в дневном лимите
+func checkBookingPolicy(q queryer, userID, slotID string, excludeBookingID string) ([]models.PolicyViolation, error) {
+	var start time.Time
+	var date string
+	err := q.QueryRow(`
+		SELECT starts_at, to_char(date, 'YYYY-MM-DD') FROM booking_slots WHERE id = $1
+	`, slotID).Scan(&start, &date)
+	if err != nil {
+		return nil, err
+	}
+
+	var violations []models.PolicyViolation
+	now := time.Now()
+
+	if !start.After(now) {
+		violations = append(violations, models.PolicyViolation{
+			Code:    ViolationSlotInPast,
+			Message: "Slot has already started",
+		})
+	} else if start.Before(now.Add(time.Duration(models.MinBookingHours) * time.Hour)) {
+		violations = append(violations, models.PolicyViolation{
+			Code:    ViolationMinLeadTime,
+			Message: fmt.Sprintf("Slot must be booked at least %d hours in advance", models.MinBookingHours),
+		})
+	}
+
+	if start.After(now.AddDate(0, 0, models.BookingWindowDays)) {
+		violations = append(violations, models.PolicyViolation{
+			Code:    ViolationBookingWindow,
+			Message: fmt.Sprintf("Slot is more than %d days ahead", models.BookingWindowDays),
+		})
+	}
+
+	// Бронирования одного комплекта считаются одним бронированием
+	var dailyCount int
+	err = q.QueryRow(`
+		SELECT COUNT(DISTINCT COALESCE(b.bundle_booking_id, b.id))
+		FROM bookings b
+		JOIN booking_slots bs ON b.slot_id = bs.id
+		WHERE b.user_id = $1 AND bs.date = $2::date
+		  AND b.status NOT IN ('cancelled', 'rejected')
+		  AND b.id IS DISTINCT FROM NULLIF($3, '')::uuid
+	`, userID, date, excludeBookingID).Scan(&dailyCount)
+	if err != nil {
+		return nil, err
+	}
+
+	if dailyCount >= models.MaxDailyBookings {
+		violations = append(violations, models.PolicyViolation{
+			Code:    ViolationDailyLimit,
+			Message: fmt.Sprintf("Daily limit of %d bookings reached", models.MaxDailyBookings),
+		})
+	}
+
+	if models.NoShowLimit > 0 {
+		noShows, err := countRecentNoShows(q, userID)
+		if err != nil {
+			return nil, err
+		}
+		if noShows >= models.NoShowLimit {
+			violations = append(violations, models.PolicyViolation{
+				Code: ViolationNoShows,
+				Message: fmt.Sprintf("Booking suspended: %d no-shows in the last %d days",
+					noShows, models.NoShowWindowDays),
+			})
+		}
+	}
+
+	return violations, nil
+}
+
+// countRecentNoShows возвращает число неявок пользователя за последние no_show_window_days дней
+func countRecentNoShows(q queryer, userID string) (int, error) {
+	var count int
+	err := q.QueryRow(`
+		SELECT COUNT(*)
+		FROM bookings b
+		JOIN booking_slots bs ON b.slot_id = bs.id
+		WHERE b.user_id = $1 AND b.status = 'no_show'
+		  AND `+slotStartsAtSQL+` > NOW() - make_interval(days => $2)
+	`, userID, models.NoShowWindowDays).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"booking-system/models"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// bookingSlotIDs возвращает все слоты бронирования: основной и слоты сегментов, в порядке времени
+func bookingSlotIDs(q queryer, bookingID string) ([]string, error) {
+	rows, err := q.Query(`
+		SELECT bs.id FROM booking_slots bs
+		WHERE bs.id = (SELECT slot_id FROM bookings WHERE id = $1)
+		   OR bs.id IN (SELECT slot_id FROM booking_segments WHERE booking_id = $1)
+		ORDER BY bs.start_time
+	`, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var slotIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		slotIDs = append(slotIDs, id)
+	}
+	return slotIDs, rows.Err()
+}
+
+// promoteBookingWaitlists передаёт места, освобождённые бронированием, листам ожидания всех его слотов
+func promoteBookingWaitlists(tx *sql.Tx, bookingID string) error {
+	slotIDs, err := bookingSlotIDs(tx, bookingID)
+	if err != nil {
+		return err
+	}
+	for _, slotID := range slotIDs {
+		if err := promoteWaitlist(tx, slotID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findRangeSlots возвращает слоты объекта, без разрывов покрывающие интервал дня date.
+// Промежуток между слотами, равный буферам объекта, разрывом не считается
+func findRangeSlots(q queryer, itemID, date string, window clockRange) ([]string, error) {
+	buffers, err := loadItemBuffers(q, itemID)
+	if err == sql.ErrNoRows {
+		return nil, &bookingError{Status: http.StatusNotFound, Message: "Item not found"}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.Query(`
+		SELECT id, start_time::text, end_time::text FROM booking_slots
+		WHERE item_id = $1 AND date = $2 AND start_time >= $3::time AND end_time <= $4::time AND removed_at IS NULL
+		ORDER BY start_time
+	`, itemID, date, formatClock(window.Start), formatClock(window.End))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var slotIDs []string
+	cursor := window.Start
+	for rows.Next() {
+		var id, startTime, endTime string
+		if err := rows.Scan(&id, &startTime, &endTime); err != nil {
+			return nil, err
+		}
+		start, err := parseClock(startTime)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseClock(endTime)
+		if err != nil {
+			return nil, err
+		}
+		if start != cursor && (len(slotIDs) == 0 || start != cursor+buffers.gap()) {
+			break
+		}
+		slotIDs = append(slotIDs, id)
+		cursor = end
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if cursor != window.End {
+		return nil, &bookingError{Status: http.StatusConflict, Message: "Requested range is not covered by consecutive slots"}
+	}
+	return slotIDs, nil
+}
+
+// bookSlots бронирует participants мест сразу в нескольких подряд идущих слотах как одно бронирование.
+// Все слоты блокируются в порядке ID; правила бронирования проверяются один раз по началу первого слота
+func bookSlots(tx *sql.Tx, userID string, slotIDs []string, participants int) (string, error) {
+	if _, err := lockAndCheckSlots(tx, userID, slotIDs, participants); err != nil {
+		return "", err
+	}
+
+	if err := checkPolicyViolations(tx, userID, slotIDs[0], ""); err != nil {
+		return "", err
+	}
+
+	return insertBooking(tx, userID, slotIDs, participants)
+}
+
+// lockAndCheckSlots блокирует слоты в порядке ID и проверяет, что в каждом есть participants свободных мест.
+// Возвращает момент начала первого слота
+func lockAndCheckSlots(tx *sql.Tx, userID string, slotIDs []string, participants int) (time.Time, error) {
+	_, err := tx.Exec(`SELECT id FROM booking_slots WHERE id = ANY($1) ORDER BY id FOR UPDATE`, pq.Array(slotIDs))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var start time.Time
+	for i, slotID := range slotIDs {
+		slotStartsAt, err := checkSlotAvailability(tx, userID, slotID, participants, "")
+		if err != nil {
+			return time.Time{}, err
+		}
+		if i == 0 {
+			start = slotStartsAt
+		}
+	}
+	return start, nil
+}
+
+// insertBooking создаёт бронирование уже проверенных слотов: первый слот основной, остальные — сегменты
+func insertBooking(tx *sql.Tx, userID string, slotIDs []string, participants int) (string, error) {
+	var bookingID string
+	err := tx.QueryRow(`
+		INSERT INTO bookings (user_id, slot_id, participants, status)
+		SELECT $1, bs.id, $3, CASE WHEN bi.requires_approval THEN 'pending' ELSE 'confirmed' END
+		FROM booking_slots bs
+		JOIN booking_items bi ON bs.item_id = bi.id
+		WHERE bs.id = $2
+		RETURNING id
+	`, userID, slotIDs[0], participants).Scan(&bookingID)
+	if err != nil {
+		return "", err
+	}
+
+	for _, slotID := range slotIDs[1:] {
+		_, err := tx.Exec("INSERT INTO booking_segments (booking_id, slot_id) VALUES ($1, $2)", bookingID, slotID)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return bookingID, nil
+}
+
+func ApiBookRangeHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	userID, ok := session.Values["user_id"].(string)
+	if !ok {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		ItemID       string `json:"item_id"`
+		Date         string `json:"date"`
+		StartTime    string `json:"start_time"`
+		EndTime      string `json:"end_time"`
+		Participants int    `json:"participants"`
+		// Вместо date/start_time/end_time можно передать моменты в RFC 3339
+		StartsAt string `json:"starts_at"`
+		EndsAt   string `json:"ends_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	if req.Participants == 0 {
+		req.Participants = 1
+	}
+	if req.ItemID == "" {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "item_id is required"})
+		return
+	}
+	if req.StartsAt != "" || req.EndsAt != "" {
+		loc, err := itemLocation(models.DB, req.ItemID)
+		if err != nil {
+			respondBookingError(w, err)
+			return
+		}
+		startsAt, err1 := time.Parse(time.RFC3339, req.StartsAt)
+		endsAt, err2 := time.Parse(time.RFC3339, req.EndsAt)
+		if err1 != nil || err2 != nil {
+			respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "starts_at and ends_at must be RFC 3339 timestamps"})
+			return
+		}
+		startsAt, endsAt = startsAt.In(loc), endsAt.In(loc)
+		if endsAt.Format("2006-01-02") != startsAt.Format("2006-01-02") {
+			respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "The range must lie within one day of the item's time zone"})
+			return
+		}
+		req.Date, req.StartTime, req.EndTime = startsAt.Format("2006-01-02"), startsAt.Format("15:04"), endsAt.Format("15:04")
+	}
+	if _, err := time.ParseInLocation("2006-01-02", req.Date, time.Local); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid date"})
+		return
+	}
+
+	var window clockRange
+	var err error
+	if window.Start, err = parseClock(req.StartTime); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid start_time"})
+		return
+	}
+	if window.End, err = parseClock(req.EndTime); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid end_time"})
+		return
+	}
+	if window.End <= window.Start {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "end_time must be after start_time"})
+		return
+	}
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	slotIDs, err := findRangeSlots(tx, req.ItemID, req.Date, window)
+	if err != nil {
+		respondBookingError(w, err)
+		return
+	}
+
+	bookingID, err := bookSlots(tx, userID, slotIDs, req.Participants)
+	if err != nil {
+		respondBookingError(w, err)
+		return
+	}
+
+	var status string
+	if err := tx.QueryRow("SELECT status FROM bookings WHERE id = $1", bookingID).Scan(&status); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"id":       bookingID,
+		"status":   status,
+		"slot_ids": slotIDs,
+	})
+}
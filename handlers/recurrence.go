@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSeriesOccurrences ограничивает количество повторений одной серии
+const maxSeriesOccurrences = 100
+
+// recurrenceRule — поддерживаемое подмножество RRULE (RFC 5545): FREQ=DAILY|WEEKLY, INTERVAL, COUNT, UNTIL
+type recurrenceRule struct {
+	Freq     string
+	Interval int
+	Count    int
+	Until    time.Time
+}
+
+// parseRecurrenceRule разбирает правило вида "FREQ=WEEKLY;INTERVAL=2;COUNT=10".
+// Префикс "RRULE:" допускается; COUNT или UNTIL обязателен. UNTIL без пояса понимается в поясе loc
+func parseRecurrenceRule(s string, loc *time.Location) (recurrenceRule, error) {
+	rule := recurrenceRule{Interval: 1}
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return rule, fmt.Errorf("invalid rule part %q", part)
+		}
+		key, value := strings.ToUpper(strings.TrimSpace(kv[0])), strings.TrimSpace(kv[1])
+
+		switch key {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(value)
+			if rule.Freq != "DAILY" && rule.Freq != "WEEKLY" {
+				return rule, fmt.Errorf("unsupported FREQ %q: only DAILY and WEEKLY are supported", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return rule, fmt.Errorf("INTERVAL must be a positive integer")
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return rule, fmt.Errorf("COUNT must be a positive integer")
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := parseUntil(value, loc)
+			if err != nil {
+				return rule, err
+			}
+			rule.Until = until
+		default:
+			return rule, fmt.Errorf("unsupported rule part %q", key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return rule, fmt.Errorf("FREQ is required")
+	}
+	if rule.Count == 0 && rule.Until.IsZero() {
+		return rule, fmt.Errorf("COUNT or UNTIL is required")
+	}
+	if rule.Count > maxSeriesOccurrences {
+		return rule, fmt.Errorf("COUNT must not exceed %d", maxSeriesOccurrences)
+	}
+	return rule, nil
+}
+
+// parseUntil принимает UNTIL в форматах 20060102, 20060102T150405[Z] или 2006-01-02.
+// Значение с Z задано в UTC, остальные — «плавающее» время в поясе loc. Дата без времени включает весь день
+func parseUntil(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("20060102T150405", value, loc); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"20060102", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t.AddDate(0, 0, 1).Add(-time.Second), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid UNTIL %q", value)
+}
+
+// occurrences возвращает моменты начала всех повторений, начиная с start
+func (rule recurrenceRule) occurrences(start time.Time) []time.Time {
+	step := rule.Interval
+	if rule.Freq == "WEEKLY" {
+		step *= 7
+	}
+
+	var result []time.Time
+	for i := 0; i < maxSeriesOccurrences; i++ {
+		occurrence := start.AddDate(0, 0, i*step)
+		if !rule.Until.IsZero() && occurrence.After(rule.Until) {
+			break
+		}
+		if rule.Count > 0 && len(result) >= rule.Count {
+			break
+		}
+		result = append(result, occurrence)
+	}
+	return result
+}
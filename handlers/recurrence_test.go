@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s is not available: %v", name, err)
+	}
+	return loc
+}
+
+func TestParseRecurrenceRule(t *testing.T) {
+	moscow := mustLoadLocation(t, "Europe/Moscow")
+
+	tests := []struct {
+		name    string
+		rule    string
+		want    recurrenceRule
+		wantErr bool
+	}{
+		{
+			name: "weekly with count",
+			rule: "FREQ=WEEKLY;COUNT=10",
+			want: recurrenceRule{Freq: "WEEKLY", Interval: 1, Count: 10},
+		},
+		{
+			name: "prefix, lower case and interval",
+			rule: "RRULE:freq=daily;interval=2;count=3",
+			want: recurrenceRule{Freq: "DAILY", Interval: 2, Count: 3},
+		},
+		{
+			name: "UTC until",
+			rule: "FREQ=DAILY;UNTIL=20240110T090000Z",
+			want: recurrenceRule{Freq: "DAILY", Interval: 1, Until: time.Date(2024, 1, 10, 9, 0, 0, 0, time.UTC)},
+		},
+		{
+			name: "floating until in the item's zone",
+			rule: "FREQ=DAILY;UNTIL=20240110T090000",
+			want: recurrenceRule{Freq: "DAILY", Interval: 1, Until: time.Date(2024, 1, 10, 9, 0, 0, 0, moscow)},
+		},
+		{
+			name: "date until includes the whole day",
+			rule: "FREQ=WEEKLY;UNTIL=2024-01-10",
+			want: recurrenceRule{Freq: "WEEKLY", Interval: 1, Until: time.Date(2024, 1, 10, 23, 59, 59, 0, moscow)},
+		},
+		{name: "missing freq", rule: "COUNT=3", wantErr: true},
+		{name: "missing count and until", rule: "FREQ=DAILY", wantErr: true},
+		{name: "unsupported freq", rule: "FREQ=MONTHLY;COUNT=3", wantErr: true},
+		{name: "unsupported part", rule: "FREQ=WEEKLY;BYDAY=MO;COUNT=3", wantErr: true},
+		{name: "zero interval", rule: "FREQ=DAILY;INTERVAL=0;COUNT=3", wantErr: true},
+		{name: "negative count", rule: "FREQ=DAILY;COUNT=-1", wantErr: true},
+		{name: "count over limit", rule: "FREQ=DAILY;COUNT=101", wantErr: true},
+		{name: "invalid until", rule: "FREQ=DAILY;UNTIL=tomorrow", wantErr: true},
+		{name: "part without value", rule: "FREQ", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRecurrenceRule(tt.rule, moscow)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Freq != tt.want.Freq || got.Interval != tt.want.Interval || got.Count != tt.want.Count ||
+				!got.Until.Equal(tt.want.Until) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecurrenceOccurrences(t *testing.T) {
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+	start := time.Date(2024, 3, 4, 10, 0, 0, 0, berlin)
+
+	tests := []struct {
+		name string
+		rule recurrenceRule
+		want []string
+	}{
+		{
+			name: "daily count",
+			rule: recurrenceRule{Freq: "DAILY", Interval: 1, Count: 3},
+			want: []string{"2024-03-04 10:00", "2024-03-05 10:00", "2024-03-06 10:00"},
+		},
+		{
+			name: "every second week",
+			rule: recurrenceRule{Freq: "WEEKLY", Interval: 2, Count: 3},
+			want: []string{"2024-03-04 10:00", "2024-03-18 10:00", "2024-04-01 10:00"},
+		},
+		{
+			name: "until is inclusive",
+			rule: recurrenceRule{Freq: "WEEKLY", Interval: 1, Until: time.Date(2024, 3, 18, 10, 0, 0, 0, berlin)},
+			want: []string{"2024-03-04 10:00", "2024-03-11 10:00", "2024-03-18 10:00"},
+		},
+		{
+			name: "count and until, whichever comes first",
+			rule: recurrenceRule{Freq: "DAILY", Interval: 1, Count: 10, Until: time.Date(2024, 3, 5, 12, 0, 0, 0, berlin)},
+			want: []string{"2024-03-04 10:00", "2024-03-05 10:00"},
+		},
+		{
+			// 31 марта 2024 в Берлине переход на летнее время: местное время повторений не меняется
+			name: "wall clock is kept across DST",
+			rule: recurrenceRule{Freq: "WEEKLY", Interval: 1, Count: 5},
+			want: []string{"2024-03-04 10:00", "2024-03-11 10:00", "2024-03-18 10:00", "2024-03-25 10:00", "2024-04-01 10:00"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, occurrence := range tt.rule.occurrences(start) {
+				got = append(got, occurrence.In(berlin).Format("2006-01-02 15:04"))
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("occurrence %d: got %s, want %s", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	limited := recurrenceRule{Freq: "DAILY", Interval: 1, Until: start.AddDate(5, 0, 0)}.occurrences(start)
+	if len(limited) != maxSeriesOccurrences {
+		t.Errorf("got %d occurrences, want at most %d", len(limited), maxSeriesOccurrences)
+	}
+}
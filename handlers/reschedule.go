@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"booking-system/models"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// logBookingEvent записывает событие в историю бронирования
+func logBookingEvent(tx *sql.Tx, bookingID, event string, fromSlotID, toSlotID *string, actorID string) error {
+	_, err := tx.Exec(`
+		INSERT INTO booking_events (booking_id, event, from_slot_id, to_slot_id, actor_id)
+		VALUES ($1, $2, $3, $4, NULLIF($5, '')::uuid)
+	`, bookingID, event, fromSlotID, toSlotID, actorID)
+	return err
+}
+
+// rescheduleBooking переносит активное бронирование в другой слот (возможно, другого объекта),
+// сохраняя его ID. Оба слота блокируются в порядке ID и раньше самого бронирования, как при бронировании
+// и подтверждении, чтобы встречные переносы не приводили к взаимоблокировке
+func rescheduleBooking(tx *sql.Tx, bookingID, targetSlotID, actorID string) error {
+	var currentSlotID string
+	err := tx.QueryRow("SELECT slot_id FROM bookings WHERE id = $1", bookingID).Scan(&currentSlotID)
+	if err == sql.ErrNoRows {
+		return &bookingError{Status: http.StatusNotFound, Message: "Booking not found"}
+	}
+	if err != nil {
+		return err
+	}
+	if currentSlotID == targetSlotID {
+		return &bookingError{Status: http.StatusBadRequest, Message: "Booking is already in this slot"}
+	}
+
+	_, err = tx.Exec(`
+		SELECT id FROM booking_slots WHERE id IN ($1, $2) ORDER BY id FOR UPDATE
+	`, currentSlotID, targetSlotID)
+	if err != nil {
+		return &bookingError{Status: http.StatusNotFound, Message: "Slot not found"}
+	}
+
+	var userID, lockedSlotID, status string
+	var participants int
+	err = tx.QueryRow(`
+		SELECT user_id, slot_id, participants, status FROM bookings WHERE id = $1 FOR UPDATE
+	`, bookingID).Scan(&userID, &lockedSlotID, &participants, &status)
+	if err != nil {
+		return err
+	}
+	// Пока слоты блокировались, бронирование успели перенести
+	if lockedSlotID != currentSlotID {
+		return &bookingError{Status: http.StatusConflict, Message: "Booking was changed, please try again"}
+	}
+
+	if status != "confirmed" && status != "pending" {
+		return &bookingError{Status: http.StatusConflict, Message: "Only active bookings can be rescheduled"}
+	}
+
+	var hasSegments, inBundle bool
+	err = tx.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM booking_segments WHERE booking_id = $1),
+		       (SELECT bundle_booking_id IS NOT NULL FROM bookings WHERE id = $1)
+	`, bookingID).Scan(&hasSegments, &inBundle)
+	if err != nil {
+		return err
+	}
+	if hasSegments {
+		return &bookingError{Status: http.StatusConflict, Message: "Multi-slot bookings cannot be rescheduled"}
+	}
+	if inBundle {
+		return &bookingError{Status: http.StatusConflict, Message: "Bundle bookings cannot be rescheduled"}
+	}
+
+	// Перенос освобождает текущий слот так же, как отмена, поэтому подчиняется правилам отмены,
+	// но без поздней отмены со штрафом: иначе её можно было бы обойти переносом на дальний слот
+	// и бесплатной отменой
+	var start time.Time
+	var freeCancelHours *int
+	var lateCancelAction *string
+	err = tx.QueryRow(`
+		SELECT bs.starts_at, bi.free_cancel_hours, bi.late_cancel_action
+		FROM booking_slots bs
+		JOIN booking_items bi ON bs.item_id = bi.id
+		WHERE bs.id = $1
+	`, currentSlotID).Scan(&start, &freeCancelHours, &lateCancelAction)
+	if err != nil {
+		return err
+	}
+	policy := itemCancellationPolicy(freeCancelHours, lateCancelAction)
+	if _, err := cancellationViolation(policy, status, start, time.Now(), "rescheduled", false); err != nil {
+		return err
+	}
+
+	if err := validateSlotBooking(tx, userID, targetSlotID, participants, bookingID); err != nil {
+		return err
+	}
+
+	// Перенос в объект, требующий подтверждения, снова отправляет бронирование на подтверждение
+	_, err = tx.Exec(`
+		UPDATE bookings b
+		SET slot_id = bs.id,
+		    status = CASE WHEN bi.requires_approval THEN 'pending' ELSE 'confirmed' END,
+		    updated_at = NOW()
+		FROM booking_slots bs
+		JOIN booking_items bi ON bs.item_id = bi.id
+		WHERE b.id = $1 AND bs.id = $2
+	`, bookingID, targetSlotID)
+	if err != nil {
+		return err
+	}
+
+	if err := logBookingEvent(tx, bookingID, "rescheduled", &currentSlotID, &targetSlotID, actorID); err != nil {
+		return err
+	}
+
+	return promoteWaitlist(tx, currentSlotID)
+}
+
+func ApiRescheduleBookingHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	userID, ok := session.Values["user_id"].(string)
+	if !ok {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	vars := mux.Vars(r)
+	bookingID := vars["id"]
+
+	var req struct {
+		SlotID string `json:"slot_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SlotID == "" {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "slot_id is required"})
+		return
+	}
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	var owner string
+	err = tx.QueryRow("SELECT user_id FROM bookings WHERE id = $1", bookingID).Scan(&owner)
+	if err != nil || owner != userID {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Booking not found"})
+		return
+	}
+
+	if err := rescheduleBooking(tx, bookingID, req.SlotID, userID); err != nil {
+		respondBookingError(w, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"id":      bookingID,
+		"slot_id": req.SlotID,
+	})
+}
@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"booking-system/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// itemSchedule — недельное расписание объекта по дням недели (0 = воскресенье).
+// nil означает, что у объекта нет собственного расписания
+type itemSchedule map[int]*models.DaySchedule
+
+// loadItemSchedule загружает расписание объекта; возвращает nil, если шаблона нет
+func loadItemSchedule(q queryer, itemID string) (itemSchedule, error) {
+	rows, err := q.Query(`
+		SELECT weekday, COALESCE(slot_duration_minutes, 0)
+		FROM item_schedule_days
+		WHERE item_id = $1
+	`, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule := make(itemSchedule)
+	for rows.Next() {
+		day := &models.DaySchedule{Open: []models.TimeInterval{}, Breaks: []models.TimeInterval{}}
+		if err := rows.Scan(&day.Weekday, &day.SlotDurationMinutes); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		schedule[day.Weekday] = day
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(schedule) == 0 {
+		return nil, nil
+	}
+
+	rows, err = q.Query(`
+		SELECT weekday, to_char(start_time, 'HH24:MI'), to_char(end_time, 'HH24:MI'), is_break
+		FROM item_schedule_intervals
+		WHERE item_id = $1
+		ORDER BY weekday, start_time
+	`, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var weekday int
+		var interval models.TimeInterval
+		var isBreak bool
+		if err := rows.Scan(&weekday, &interval.Start, &interval.End, &isBreak); err != nil {
+			return nil, err
+		}
+		day, ok := schedule[weekday]
+		if !ok {
+			continue
+		}
+		if isBreak {
+			day.Breaks = append(day.Breaks, interval)
+		} else {
+			day.Open = append(day.Open, interval)
+		}
+	}
+
+	return schedule, rows.Err()
+}
+
+// defaultDaySchedule строит расписание дня по глобальным system_settings
+func defaultDaySchedule(weekday int) *models.DaySchedule {
+	return &models.DaySchedule{
+		Weekday:             weekday,
+		SlotDurationMinutes: models.SlotDuration,
+		Open:                []models.TimeInterval{{Start: models.DayStart, End: models.DayEnd}},
+		Breaks:              []models.TimeInterval{},
+	}
+}
+
+// parseIntervals разбирает интервалы расписания в минуты от полуночи
+func parseIntervals(intervals []models.TimeInterval) ([]clockRange, error) {
+	ranges := make([]clockRange, 0, len(intervals))
+	for _, interval := range intervals {
+		start, err := parseClock(interval.Start)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseClock(interval.End)
+		if err != nil {
+			return nil, err
+		}
+		if end <= start {
+			return nil, fmt.Errorf("interval %s-%s must end after it starts", interval.Start, interval.End)
+		}
+		ranges = append(ranges, clockRange{Start: start, End: end})
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+	return ranges, nil
+}
+
+// subtractRanges вычитает перерывы из интервалов работы
+func subtractRanges(open, breaks []clockRange) []clockRange {
+	result := open
+	for _, b := range breaks {
+		var next []clockRange
+		for _, o := range result {
+			if !o.overlaps(b) {
+				next = append(next, o)
+				continue
+			}
+			if o.Start < b.Start {
+				next = append(next, clockRange{Start: o.Start, End: b.Start})
+			}
+			if b.End < o.End {
+				next = append(next, clockRange{Start: b.End, End: o.End})
+			}
+		}
+		result = next
+	}
+	return result
+}
+
+// validateDaySchedule проверяет интервалы дня: интервалы работы не должны пересекаться
+func validateDaySchedule(day models.DaySchedule) error {
+	if day.Weekday < 0 || day.Weekday > 6 {
+		return fmt.Errorf("weekday must be between 0 (Sunday) and 6 (Saturday)")
+	}
+	if day.SlotDurationMinutes < 0 {
+		return fmt.Errorf("slot duration must be positive")
+	}
+	open, err := parseIntervals(day.Open)
+	if err != nil {
+		return err
+	}
+	for i := 1; i < len(open); i++ {
+		if open[i].overlaps(open[i-1]) {
+			return fmt.Errorf("open intervals overlap on weekday %d", day.Weekday)
+		}
+	}
+	if _, err := parseIntervals(day.Breaks); err != nil {
+		return err
+	}
+	return nil
+}
+
+// daySlotPlan возвращает слоты, которые должны существовать у объекта в указанный день,
+// по расписанию объекта или, если его нет, по системным настройкам, с промежутками под буферы объекта
+func daySlotPlan(schedule itemSchedule, day time.Time, buffers itemBuffers) ([]clockRange, error) {
+	weekday := int(day.Weekday())
+
+	daySchedule := defaultDaySchedule(weekday)
+	if schedule != nil {
+		var ok bool
+		if daySchedule, ok = schedule[weekday]; !ok {
+			return nil, nil
+		}
+	}
+
+	duration := daySchedule.SlotDurationMinutes
+	if duration <= 0 {
+		duration = models.SlotDuration
+	}
+
+	open, err := parseIntervals(daySchedule.Open)
+	if err != nil {
+		return nil, err
+	}
+	breaks, err := parseIntervals(daySchedule.Breaks)
+	if err != nil {
+		return nil, err
+	}
+
+	var slots []clockRange
+	for _, window := range subtractRanges(open, breaks) {
+		slots = append(slots, splitIntoSlots(window, duration, buffers)...)
+	}
+	return slots, nil
+}
+
+func ApiGetItemScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	itemID := vars["id"]
+
+	var exists bool
+	err := models.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM booking_items WHERE id = $1)", itemID).Scan(&exists)
+	if err != nil || !exists {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Item not found"})
+		return
+	}
+
+	schedule, err := loadItemSchedule(models.DB, itemID)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	days := []*models.DaySchedule{}
+	for weekday := 0; weekday < 7; weekday++ {
+		if schedule == nil {
+			days = append(days, defaultDaySchedule(weekday))
+		} else if day, ok := schedule[weekday]; ok {
+			days = append(days, day)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"item_id":    itemID,
+		"is_default": schedule == nil,
+		"days":       days,
+	})
+}
+
+func ApiUpdateItemScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	role, ok := session.Values["role"].(string)
+	if !ok || (role != "admin" && role != "manager") {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	vars := mux.Vars(r)
+	itemID := vars["id"]
+
+	var req struct {
+		Days []models.DaySchedule `json:"days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+
+	if len(req.Days) == 0 {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "At least one day is required; use DELETE to fall back to system settings"})
+		return
+	}
+
+	seen := make(map[int]bool)
+	for _, day := range req.Days {
+		if err := validateDaySchedule(day); err != nil {
+			respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if seen[day.Weekday] {
+			respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Weekday %d is listed twice", day.Weekday)})
+			return
+		}
+		seen[day.Weekday] = true
+	}
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	var lockedID string
+	err = tx.QueryRow("SELECT id FROM booking_items WHERE id = $1 FOR UPDATE", itemID).Scan(&lockedID)
+	if err == sql.ErrNoRows {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Item not found"})
+		return
+	}
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Интервалы удаляются каскадно вместе с днями
+	if _, err := tx.Exec("DELETE FROM item_schedule_days WHERE item_id = $1", itemID); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	for _, day := range req.Days {
+		_, err := tx.Exec(`
+			INSERT INTO item_schedule_days (item_id, weekday, slot_duration_minutes)
+			VALUES ($1, $2, NULLIF($3, 0))
+		`, itemID, day.Weekday, day.SlotDurationMinutes)
+		if err != nil {
+			respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		for _, interval := range day.Open {
+			if err := insertScheduleInterval(tx, itemID, day.Weekday, interval, false); err != nil {
+				respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+		}
+		for _, interval := range day.Breaks {
+			if err := insertScheduleInterval(tx, itemID, day.Weekday, interval, true); err != nil {
+				respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+func insertScheduleInterval(tx *sql.Tx, itemID string, weekday int, interval models.TimeInterval, isBreak bool) error {
+	_, err := tx.Exec(`
+		INSERT INTO item_schedule_intervals (item_id, weekday, start_time, end_time, is_break)
+		VALUES ($1, $2, $3, $4, $5)
+	`, itemID, weekday, interval.Start, interval.End, isBreak)
+	return err
+}
+
+func ApiDeleteItemScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	role, ok := session.Values["role"].(string)
+	if !ok || (role != "admin" && role != "manager") {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	vars := mux.Vars(r)
+	itemID := vars["id"]
+
+	_, err := models.DB.Exec("DELETE FROM item_schedule_days WHERE item_id = $1", itemID)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"booking-system/models"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// maxSearchDays — максимальная длина интервала поиска свободных слотов
+	maxSearchDays = 31
+	// maxWindowsPerItem — сколько подходящих интервалов возвращается для одного объекта
+	maxWindowsPerItem = 20
+)
+
+// searchSlot — свободный слот-кандидат для поиска
+type searchSlot struct {
+	ItemID    uuid.UUID
+	ItemName  string
+	Capacity  int
+	Gap       int
+	SlotID    string
+	Date      string
+	Start     int
+	End       int
+	StartsAt  time.Time
+	EndsAt    time.Time
+	Remaining int
+}
+
+// collectWindows собирает из упорядоченных по времени слотов одного объекта интервалы
+// из подряд идущих слотов длительностью не меньше duration минут.
+// Слоты, разделённые ровно буферами объекта, тоже считаются идущими подряд
+func collectWindows(slots []searchSlot, duration int) []models.AvailabilityWindow {
+	var windows []models.AvailabilityWindow
+	for i := range slots {
+		remaining := slots[i].Remaining
+		slotIDs := []string{slots[i].SlotID}
+		end := slots[i].End
+		for j := i + 1; end-slots[i].Start < duration && j < len(slots); j++ {
+			if slots[j].Date != slots[i].Date || (slots[j].Start != end && slots[j].Start != end+slots[j].Gap) {
+				break
+			}
+			slotIDs = append(slotIDs, slots[j].SlotID)
+			end = slots[j].End
+			if slots[j].Remaining < remaining {
+				remaining = slots[j].Remaining
+			}
+		}
+		if end-slots[i].Start < duration {
+			continue
+		}
+		windows = append(windows, models.AvailabilityWindow{
+			Date:           slots[i].Date,
+			StartTime:      formatClock(slots[i].Start),
+			EndTime:        formatClock(end),
+			SlotIDs:        slotIDs,
+			RemainingSeats: remaining,
+		})
+	}
+	return windows
+}
+
+// loadFreeSlots загружает открытые слоты объектов, подходящих под itemCondition (аргументы с $4),
+// в которых между from и to осталось не меньше participants мест. Слоты сгруппированы по объектам и упорядочены по времени
+func loadFreeSlots(q queryer, from, to time.Time, participants int, itemCondition string, itemArgs []interface{}) ([][]searchSlot, error) {
+	args := append([]interface{}{
+		from, to, participants,
+	}, itemArgs...)
+	rows, err := q.Query(`
+		SELECT bi.id, bi.name, COALESCE(bi.capacity, 1), bi.buffer_before_minutes + bi.buffer_after_minutes,
+		       bs.id, to_char(bs.date, 'YYYY-MM-DD'),
+		       bs.start_time::text, bs.end_time::text, `+slotStartsAtSQL+`, `+slotEndsAtSQL+`, `+remainingSeatsSQL+`
+		FROM booking_slots bs
+		JOIN booking_items bi ON bs.item_id = bi.id
+		WHERE `+slotStartsAtSQL+` >= $1 AND `+slotEndsAtSQL+` <= $2
+		  AND `+slotStartsAtSQL+` > NOW()
+		  AND `+slotOpenSQL+`
+		  AND `+remainingSeatsSQL+` >= $3
+		  AND `+itemCondition+`
+		ORDER BY bi.id, bs.date, bs.start_time
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var itemSlots [][]searchSlot
+	for rows.Next() {
+		var s searchSlot
+		var startTime, endTime string
+		if err := rows.Scan(&s.ItemID, &s.ItemName, &s.Capacity, &s.Gap, &s.SlotID, &s.Date,
+			&startTime, &endTime, &s.StartsAt, &s.EndsAt, &s.Remaining); err != nil {
+			return nil, err
+		}
+		if s.Start, err = parseClock(startTime); err != nil {
+			continue
+		}
+		if s.End, err = parseClock(endTime); err != nil {
+			continue
+		}
+		if n := len(itemSlots); n == 0 || itemSlots[n-1][0].ItemID != s.ItemID {
+			itemSlots = append(itemSlots, nil)
+		}
+		itemSlots[len(itemSlots)-1] = append(itemSlots[len(itemSlots)-1], s)
+	}
+	return itemSlots, rows.Err()
+}
+
+// parseWindowParams читает общие для поиска свободного времени параметры: длительность интервала duration
+// в минутах (0 — любой) и число мест participants (по умолчанию defaultParticipants)
+func parseWindowParams(query url.Values, defaultParticipants int) (duration, participants int, err error) {
+	participants = defaultParticipants
+	if v := query.Get("duration"); v != "" {
+		if duration, err = strconv.Atoi(v); err != nil || duration <= 0 {
+			return 0, 0, fmt.Errorf("Invalid duration")
+		}
+	}
+	if v := query.Get("participants"); v != "" {
+		if participants, err = strconv.Atoi(v); err != nil || participants <= 0 {
+			return 0, 0, fmt.Errorf("Invalid participants")
+		}
+	}
+	return duration, participants, nil
+}
+
+// ApiSearchAvailabilityHandler ищет свободные интервалы во всех объектах.
+// Объекты ранжируются по соответствию: сначала те, где остаётся меньше лишних мест, затем по раннему началу.
+// В режиме first=true возвращается только самый ранний подходящий интервал
+func ApiSearchAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	from, err := parseLocalDateTime(query.Get("from"), false)
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid from"})
+		return
+	}
+	to, err := parseLocalDateTime(query.Get("to"), true)
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid to"})
+		return
+	}
+	if !to.After(from) {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "to must be after from"})
+		return
+	}
+	if to.Sub(from) > maxSearchDays*24*time.Hour {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("Search range cannot exceed %d days", maxSearchDays),
+		})
+		return
+	}
+
+	duration, participants, err := parseWindowParams(query, 1)
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	firstOnly := query.Get("first") == "true"
+
+	filter, err := parseItemFilter(r)
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	itemCondition, itemArgs := filter.where(4)
+
+	itemSlots, err := loadFreeSlots(models.DB, from, to, participants, itemCondition, itemArgs)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	results := []models.ItemAvailability{}
+	for _, slots := range itemSlots {
+		windows := collectWindows(slots, duration)
+		if len(windows) == 0 {
+			continue
+		}
+
+		fit := -1
+		for _, win := range windows {
+			if spare := win.RemainingSeats - participants; fit < 0 || spare < fit {
+				fit = spare
+			}
+		}
+		if len(windows) > maxWindowsPerItem {
+			windows = windows[:maxWindowsPerItem]
+		}
+
+		results = append(results, models.ItemAvailability{
+			ItemID:   slots[0].ItemID,
+			ItemName: slots[0].ItemName,
+			Capacity: slots[0].Capacity,
+			Fit:      fit,
+			Windows:  windows,
+		})
+	}
+
+	earliest := func(item models.ItemAvailability) string {
+		return item.Windows[0].Date + " " + item.Windows[0].StartTime
+	}
+
+	if firstOnly {
+		// Самый ранний интервал; при равном начале предпочтение объекту с лучшим соответствием
+		sort.SliceStable(results, func(i, j int) bool {
+			if earliest(results[i]) != earliest(results[j]) {
+				return earliest(results[i]) < earliest(results[j])
+			}
+			return results[i].Fit < results[j].Fit
+		})
+		if len(results) == 0 {
+			respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "No available slots found"})
+			return
+		}
+		first := results[0]
+		first.Windows = first.Windows[:1]
+		respondWithJSON(w, http.StatusOK, first)
+		return
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Fit != results[j].Fit {
+			return results[i].Fit < results[j].Fit
+		}
+		return earliest(results[i]) < earliest(results[j])
+	})
+
+	respondWithJSON(w, http.StatusOK, results)
+}
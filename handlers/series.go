@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"booking-system/models"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// bookSeriesOccurrences бронирует слоты объекта для каждого повторения серии.
+// Бизнес-ошибки отдельных повторений возвращаются как конфликты, ошибки БД прерывают всю серию
+func bookSeriesOccurrences(tx *sql.Tx, userID, itemID, seriesID string, starts []time.Time, participants int) (booked, conflicts []models.SeriesOccurrence, err error) {
+	for _, start := range starts {
+		occurrence := models.SeriesOccurrence{Date: start.Format("2006-01-02")}
+
+		var slotID string
+		err := tx.QueryRow(`
+			SELECT id FROM booking_slots
+			WHERE item_id = $1 AND date = $2 AND start_time = $3 AND removed_at IS NULL
+		`, itemID, occurrence.Date, start.Format("15:04:05")).Scan(&slotID)
+		if err == sql.ErrNoRows {
+			occurrence.Error = "No slot at this time"
+			conflicts = append(conflicts, occurrence)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		occurrence.SlotID = &slotID
+
+		bookingID, err := bookSlot(tx, userID, slotID, participants)
+		var be *bookingError
+		if errors.As(err, &be) {
+			occurrence.Error = be.Message
+			occurrence.Violations = be.Violations
+			conflicts = append(conflicts, occurrence)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if _, err := tx.Exec("UPDATE bookings SET series_id = $2 WHERE id = $1", bookingID, seriesID); err != nil {
+			return nil, nil, err
+		}
+		occurrence.BookingID = &bookingID
+		booked = append(booked, occurrence)
+	}
+	return booked, conflicts, nil
+}
+
+func ApiCreateBookingSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	userID, ok := session.Values["user_id"].(string)
+	if !ok {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	// partial: забронировать только доступные повторения; иначе серия бронируется целиком или никак
+	var req struct {
+		ItemID       string `json:"item_id"`
+		Date         string `json:"date"`
+		StartTime    string `json:"start_time"`
+		Rule         string `json:"rule"`
+		Participants int    `json:"participants"`
+		Partial      bool   `json:"partial"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	if req.Participants == 0 {
+		req.Participants = 1
+	}
+
+	if req.ItemID == "" {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "item_id is required"})
+		return
+	}
+
+	clock, err := parseClock(req.StartTime)
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid start_time"})
+		return
+	}
+	// Повторения считаются по «настенному» времени в поясе объекта
+	loc, err := itemLocation(models.DB, req.ItemID)
+	if err != nil {
+		respondBookingError(w, err)
+		return
+	}
+	day, err := time.ParseInLocation("2006-01-02", req.Date, loc)
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid date"})
+		return
+	}
+	start := time.Date(day.Year(), day.Month(), day.Day(), clock/60, clock%60, 0, 0, loc)
+
+	rule, err := parseRecurrenceRule(req.Rule, loc)
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid rule: " + err.Error()})
+		return
+	}
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	var seriesID string
+	err = tx.QueryRow(`
+		INSERT INTO booking_series (user_id, item_id, rule, start_date, start_time, participants)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, userID, req.ItemID, req.Rule, req.Date, formatClock(clock), req.Participants).Scan(&seriesID)
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Could not create series: " + err.Error()})
+		return
+	}
+
+	booked, conflicts, err := bookSeriesOccurrences(tx, userID, req.ItemID, seriesID, rule.occurrences(start), req.Participants)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if len(booked) == 0 || (len(conflicts) > 0 && !req.Partial) {
+		// Транзакция откатывается, поэтому бронирований доступных повторений не существует
+		for i := range booked {
+			booked[i].BookingID = nil
+		}
+		respondWithJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":     "Some occurrences cannot be booked",
+			"conflicts": conflicts,
+			"available": booked,
+		})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"id":        seriesID,
+		"booked":    booked,
+		"conflicts": conflicts,
+	})
+}
+
+func ApiGetBookingSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	userID, ok := session.Values["user_id"].(string)
+	if !ok {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	rows, err := models.DB.Query(`
+		SELECT id, item_id, rule, to_char(start_date, 'YYYY-MM-DD'), to_char(start_time, 'HH24:MI'),
+		       participants, status, created_at
+		FROM booking_series
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	series := []models.BookingSeries{}
+	for rows.Next() {
+		var s models.BookingSeries
+		rows.Scan(&s.ID, &s.ItemID, &s.Rule, &s.StartDate, &s.StartTime, &s.Participants, &s.Status, &s.CreatedAt)
+		series = append(series, s)
+	}
+
+	respondWithJSON(w, http.StatusOK, series)
+}
+
+func ApiCancelBookingSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	userID, ok := session.Values["user_id"].(string)
+	if !ok {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	vars := mux.Vars(r)
+	seriesID := vars["id"]
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	var lockedID string
+	err = tx.QueryRow(`
+		SELECT id FROM booking_series WHERE id = $1 AND user_id = $2 AND status = 'active' FOR UPDATE
+	`, seriesID, userID).Scan(&lockedID)
+	if err != nil {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Series not found"})
+		return
+	}
+
+	// Отменяются только будущие активные повторения; прошедшие остаются в истории
+	rows, err := tx.Query(`
+		SELECT b.id FROM bookings b
+		JOIN booking_slots bs ON b.slot_id = bs.id
+		WHERE b.series_id = $1 AND b.status IN ('confirmed', 'pending') AND `+slotStartsAtSQL+` > NOW()
+		FOR UPDATE OF b
+	`, seriesID)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	var bookingIDs []string
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		bookingIDs = append(bookingIDs, id)
+	}
+	rows.Close()
+
+	// Повторения, которые по правилам отмены уже нельзя отменить, остаются в силе
+	cancelled, kept, penalties := 0, 0, 0
+	for _, bookingID := range bookingIDs {
+		penalty, err := cancelBookingByUser(tx, bookingID, "Series cancelled")
+		var be *bookingError
+		if errors.As(err, &be) && be.Status == http.StatusForbidden {
+			kept++
+			continue
+		}
+		if err != nil {
+			respondBookingError(w, err)
+			return
+		}
+		cancelled++
+		if penalty {
+			penalties++
+		}
+	}
+
+	_, err = tx.Exec("UPDATE booking_series SET status = 'cancelled', updated_at = NOW() WHERE id = $1", seriesID)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]int{
+		"cancelled": cancelled,
+		"kept":      kept,
+		"penalties": penalties,
+	})
+}
@@ -0,0 +1,384 @@
+package handlers
+
+import (
+	"booking-system/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// existingSlot — текущий слот объекта с занятостью. hasBookings — на слот есть хоть одно бронирование,
+// включая завершённые и отменённые
+type existingSlot struct {
+	slot        models.BookingSlot
+	taken       int
+	hasBookings bool
+}
+
+// slotOverlapError — слоты пересекаются с другими слотами того же объекта
+type slotOverlapError struct {
+	Overlaps []models.SlotOverlap
+}
+
+func (e *slotOverlapError) Error() string {
+	return "Slots overlap with other slots of the same item"
+}
+
+// findOverlaps находит пересекающиеся пары среди слотов одного объекта
+func findOverlaps(slots []models.BookingSlot) []models.SlotOverlap {
+	type span struct {
+		slot       models.BookingSlot
+		start, end int
+	}
+	spans := make([]span, 0, len(slots))
+	for _, slot := range slots {
+		start, err := parseClock(slot.StartTime)
+		if err != nil {
+			continue
+		}
+		end, err := parseClock(slot.EndTime)
+		if err != nil {
+			continue
+		}
+		spans = append(spans, span{slot: slot, start: start, end: end})
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].slot.Date != spans[j].slot.Date {
+			return spans[i].slot.Date < spans[j].slot.Date
+		}
+		return spans[i].start < spans[j].start
+	})
+
+	var overlaps []models.SlotOverlap
+	for i := range spans {
+		for j := i + 1; j < len(spans) && spans[j].slot.Date == spans[i].slot.Date && spans[j].start < spans[i].end; j++ {
+			overlaps = append(overlaps, models.SlotOverlap{
+				ItemID: spans[i].slot.ItemID,
+				Date:   spans[i].slot.Date,
+				First:  spans[i].slot,
+				Second: spans[j].slot,
+			})
+		}
+	}
+	return overlaps
+}
+
+// findSlotOverlaps возвращает существующие слоты объекта, пересекающиеся с новым слотом
+func findSlotOverlaps(q queryer, slot models.BookingSlot) ([]models.SlotOverlap, error) {
+	rows, err := q.Query(`
+		SELECT bs.id, bs.item_id, to_char(bs.date, 'YYYY-MM-DD'), bs.start_time::text, bs.end_time::text,
+		       bs.is_available, bs.max_participants, `+remainingSeatsSQL+`
+		FROM booking_slots bs
+		WHERE bs.item_id = $1 AND bs.date = $2 AND bs.start_time < $4::time AND bs.end_time > $3::time
+		  AND bs.removed_at IS NULL
+		ORDER BY bs.start_time
+	`, slot.ItemID, slot.Date, slot.StartTime, slot.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var overlaps []models.SlotOverlap
+	for rows.Next() {
+		var other models.BookingSlot
+		if err := rows.Scan(&other.ID, &other.ItemID, &other.Date, &other.StartTime, &other.EndTime,
+			&other.IsAvailable, &other.MaxParticipants, &other.RemainingSeats); err != nil {
+			return nil, err
+		}
+		overlaps = append(overlaps, models.SlotOverlap{ItemID: slot.ItemID, Date: slot.Date, First: slot, Second: other})
+	}
+	return overlaps, rows.Err()
+}
+
+// normalizeSlot проверяет дату и время присланного слота и приводит их к виду YYYY-MM-DD и HH:MM:SS.
+// Дата может прийти и в формате RFC3339, как её отдаёт список слотов
+func normalizeSlot(slot *models.BookingSlot) error {
+	date := slot.Date
+	if len(date) > 10 {
+		date = date[:10]
+	}
+	if _, err := time.ParseInLocation("2006-01-02", date, time.Local); err != nil {
+		return fmt.Errorf("Invalid slot date %q", slot.Date)
+	}
+	start, err := parseClock(slot.StartTime)
+	if err != nil {
+		return fmt.Errorf("Invalid start_time %q", slot.StartTime)
+	}
+	end, err := parseClock(slot.EndTime)
+	if err != nil {
+		return fmt.Errorf("Invalid end_time %q", slot.EndTime)
+	}
+	if end <= start {
+		return fmt.Errorf("Slot %s %s: end_time must be after start_time", date, slot.StartTime)
+	}
+	if slot.MaxParticipants < 0 {
+		return fmt.Errorf("max_participants cannot be negative")
+	}
+	slot.Date = date
+	slot.StartTime = formatClock(start) + ":00"
+	slot.EndTime = formatClock(end) + ":00"
+	return nil
+}
+
+// loadExistingSlots блокирует слоты объекта и возвращает их вместе с занятостью
+func loadExistingSlots(tx *sql.Tx, itemID string) (map[uuid.UUID]existingSlot, []uuid.UUID, error) {
+	rows, err := tx.Query(`
+		SELECT bs.id, bs.item_id, to_char(bs.date, 'YYYY-MM-DD'), bs.start_time::text, bs.end_time::text,
+		       bs.is_available, bs.max_participants, `+takenSeatsSQL+`,
+		       EXISTS (SELECT 1 FROM bookings b WHERE `+bookingCoversSlotSQL+`)
+		FROM booking_slots bs
+		WHERE bs.item_id = $1 AND bs.removed_at IS NULL
+		ORDER BY bs.id
+		FOR UPDATE OF bs
+	`, itemID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	slots := make(map[uuid.UUID]existingSlot)
+	var order []uuid.UUID
+	for rows.Next() {
+		var e existingSlot
+		if err := rows.Scan(&e.slot.ID, &e.slot.ItemID, &e.slot.Date, &e.slot.StartTime, &e.slot.EndTime,
+			&e.slot.IsAvailable, &e.slot.MaxParticipants, &e.taken, &e.hasBookings); err != nil {
+			return nil, nil, err
+		}
+		e.slot.RemainingSeats = e.slot.MaxParticipants - e.taken
+		slots[e.slot.ID] = e
+		order = append(order, e.slot.ID)
+	}
+	return slots, order, rows.Err()
+}
+
+// planItemSlots сравнивает текущие слоты объекта с присланным списком: слоты без ID создаются,
+// слоты с ID изменяются, отсутствующие в списке удаляются, а если на них есть бронирования — снимаются
+// из расписания с сохранением истории. Слоты с бронированиями нельзя переносить,
+// а их вместимость нельзя уменьшить ниже занятых мест
+func planItemSlots(tx *sql.Tx, itemID string, posted []models.BookingSlot) (models.SlotChangePlan, error) {
+	plan := models.SlotChangePlan{
+		Created:          []models.BookingSlot{},
+		Updated:          []models.BookingSlot{},
+		Deleted:          []models.BookingSlot{},
+		Archived:         []models.BookingSlot{},
+		AffectedBookings: []models.AffectedBooking{},
+	}
+
+	itemUUID, err := uuid.Parse(itemID)
+	if err != nil {
+		return plan, &bookingError{Status: http.StatusNotFound, Message: "Item not found"}
+	}
+
+	existing, order, err := loadExistingSlots(tx, itemID)
+	if err != nil {
+		return plan, err
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	for _, slot := range posted {
+		if err := normalizeSlot(&slot); err != nil {
+			return plan, &bookingError{Status: http.StatusBadRequest, Message: err.Error()}
+		}
+		slot.ItemID = itemUUID
+
+		if slot.ID == uuid.Nil {
+			plan.Created = append(plan.Created, slot)
+			continue
+		}
+		current, ok := existing[slot.ID]
+		if !ok {
+			return plan, &bookingError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Slot %s does not belong to this item", slot.ID)}
+		}
+		if seen[slot.ID] {
+			return plan, &bookingError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Slot %s is listed twice", slot.ID)}
+		}
+		seen[slot.ID] = true
+
+		if slot.MaxParticipants == 0 {
+			slot.MaxParticipants = current.slot.MaxParticipants
+		}
+		moved := slot.Date != current.slot.Date || slot.StartTime != current.slot.StartTime || slot.EndTime != current.slot.EndTime
+		if !moved && slot.IsAvailable == current.slot.IsAvailable && slot.MaxParticipants == current.slot.MaxParticipants {
+			continue
+		}
+		if moved && current.hasBookings {
+			return plan, &bookingError{
+				Status:  http.StatusConflict,
+				Message: fmt.Sprintf("Slot %s %s has bookings and cannot be moved; reschedule its bookings first", current.slot.Date, current.slot.StartTime),
+			}
+		}
+		if slot.MaxParticipants < current.taken {
+			return plan, &bookingError{
+				Status:  http.StatusConflict,
+				Message: fmt.Sprintf("Slot %s %s already has %d seats taken", current.slot.Date, current.slot.StartTime, current.taken),
+			}
+		}
+		slot.RemainingSeats = slot.MaxParticipants - current.taken
+		plan.Updated = append(plan.Updated, slot)
+	}
+
+	updated := make(map[uuid.UUID]models.BookingSlot)
+	for _, slot := range plan.Updated {
+		updated[slot.ID] = slot
+	}
+	final := append([]models.BookingSlot{}, plan.Created...)
+	for _, id := range order {
+		switch {
+		case !seen[id] && existing[id].hasBookings:
+			plan.Archived = append(plan.Archived, existing[id].slot)
+		case !seen[id]:
+			plan.Deleted = append(plan.Deleted, existing[id].slot)
+		case updated[id].ID != uuid.Nil:
+			final = append(final, updated[id])
+		default:
+			final = append(final, existing[id].slot)
+		}
+	}
+	if overlaps := findOverlaps(final); len(overlaps) > 0 {
+		return plan, &slotOverlapError{Overlaps: overlaps}
+	}
+
+	plan.AffectedBookings, err = loadAffectedBookings(tx, plan.Archived)
+	return plan, err
+}
+
+// loadAffectedBookings возвращает активные бронирования слотов, которые будут сняты из расписания
+func loadAffectedBookings(q queryer, slots []models.BookingSlot) ([]models.AffectedBooking, error) {
+	affected := []models.AffectedBooking{}
+	if len(slots) == 0 {
+		return affected, nil
+	}
+	slotIDs := make([]string, len(slots))
+	for i, slot := range slots {
+		slotIDs[i] = slot.ID.String()
+	}
+
+	rows, err := q.Query(`
+		SELECT DISTINCT ON (b.id) b.id, bs.id, b.user_id, u.login, b.status, b.participants
+		FROM booking_slots bs
+		JOIN bookings b ON `+bookingCoversSlotSQL+`
+		JOIN users u ON b.user_id = u.id
+		WHERE bs.id = ANY($1::uuid[]) AND b.status IN ('confirmed', 'pending')
+		ORDER BY b.id, bs.date, bs.start_time
+	`, pq.Array(slotIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a models.AffectedBooking
+		if err := rows.Scan(&a.BookingID, &a.SlotID, &a.UserID, &a.UserLogin, &a.Status, &a.Participants); err != nil {
+			return nil, err
+		}
+		affected = append(affected, a)
+	}
+	return affected, rows.Err()
+}
+
+// applyItemSlots применяет план изменений
+func applyItemSlots(tx *sql.Tx, itemID string, plan *models.SlotChangePlan) error {
+	// Проверка пересечений откладывается до конца транзакции: слоты могут меняться местами
+	if _, err := tx.Exec("SET CONSTRAINTS ALL DEFERRED"); err != nil {
+		return err
+	}
+
+	for i := range plan.Created {
+		slot := &plan.Created[i]
+		err := tx.QueryRow(`
+			INSERT INTO booking_slots (item_id, date, start_time, end_time, is_available, max_participants)
+			VALUES ($1, $2, $3, $4, $5, `+slotCapacitySQL(1, 6)+`)
+			RETURNING id, max_participants
+		`, itemID, slot.Date, slot.StartTime, slot.EndTime, slot.IsAvailable, slot.MaxParticipants,
+		).Scan(&slot.ID, &slot.MaxParticipants)
+		if err != nil {
+			return err
+		}
+		slot.RemainingSeats = slot.MaxParticipants
+	}
+
+	for _, slot := range plan.Updated {
+		_, err := tx.Exec(`
+			UPDATE booking_slots
+			SET date = $2, start_time = $3, end_time = $4, is_available = $5, max_participants = $6
+			WHERE id = $1
+		`, slot.ID, slot.Date, slot.StartTime, slot.EndTime, slot.IsAvailable, slot.MaxParticipants)
+		if err != nil {
+			return err
+		}
+	}
+
+	return removeSlots(tx, plan)
+}
+
+// removeSlots удаляет слоты плана без бронирований и снимает из расписания слоты с бронированиями:
+// их активные бронирования отменяются с уведомлением пользователей, а сами бронирования и их история сохраняются.
+// Листы ожидания и удержания мест закрываются вместе со слотами
+func removeSlots(tx *sql.Tx, plan *models.SlotChangePlan) error {
+	removed := append(append([]models.BookingSlot{}, plan.Deleted...), plan.Archived...)
+	if len(removed) == 0 {
+		return nil
+	}
+	removedIDs := make([]string, len(removed))
+	for i, slot := range removed {
+		removedIDs[i] = slot.ID.String()
+	}
+
+	// Лист ожидания закрывается до отмены, чтобы места не предлагались в исчезающих слотах
+	if _, err := tx.Exec("DELETE FROM waitlist_entries WHERE slot_id = ANY($1::uuid[])", pq.Array(removedIDs)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM slot_holds WHERE slot_id = ANY($1::uuid[])", pq.Array(removedIDs)); err != nil {
+		return err
+	}
+
+	for _, a := range plan.AffectedBookings {
+		var be *bookingError
+		err := cancelBooking(tx, a.BookingID.String(), "Slot removed")
+		if errors.As(err, &be) && be.Status == http.StatusConflict {
+			// Уже отменено вместе с другим бронированием комплекта
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		slot := slotByID(plan.Archived, a.SlotID)
+		message := fmt.Sprintf("Your booking on %s at %s was cancelled because the slot was removed", slot.Date, slot.StartTime)
+		if err := notifyUser(tx, a.UserID.String(), message); err != nil {
+			return err
+		}
+	}
+
+	archivedIDs := make([]string, len(plan.Archived))
+	for i, slot := range plan.Archived {
+		archivedIDs[i] = slot.ID.String()
+	}
+	_, err := tx.Exec(`
+		UPDATE booking_slots SET removed_at = NOW(), is_available = false WHERE id = ANY($1::uuid[])
+	`, pq.Array(archivedIDs))
+	if err != nil {
+		return err
+	}
+
+	deletedIDs := make([]string, len(plan.Deleted))
+	for i, slot := range plan.Deleted {
+		deletedIDs[i] = slot.ID.String()
+	}
+	_, err = tx.Exec("DELETE FROM booking_slots WHERE id = ANY($1::uuid[])", pq.Array(deletedIDs))
+	return err
+}
+
+// slotByID находит слот в списке по ID
+func slotByID(slots []models.BookingSlot, id uuid.UUID) models.BookingSlot {
+	for _, slot := range slots {
+		if slot.ID == id {
+			return slot
+		}
+	}
+	return models.BookingSlot{}
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"booking-system/models"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LoadDBTimeZone читает пояс сервера БД: в нём SQL-функции item_time_zone и location_time_zone
+// понимают объекты без своего пояса, и в нём же их должен понимать Go
+func LoadDBTimeZone() {
+	var name string
+	if err := models.DB.QueryRow("SHOW TimeZone").Scan(&name); err != nil {
+		log.Println("Using the local time zone for the database:", err)
+		return
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("Unknown database time zone %q, using the local one", name)
+		return
+	}
+	models.DBLocation = loc
+}
+
+// loadZone возвращает часовой пояс по имени IANA; пустое или неизвестное имя — пояс сервера БД
+func loadZone(name string) *time.Location {
+	if name == "" {
+		return models.DBLocation
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return models.DBLocation
+	}
+	return loc
+}
+
+// validateTimeZone проверяет, что name — известный пояс IANA. Пустое имя допустимо и означает «наследовать»
+func validateTimeZone(name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return fmt.Errorf("Unknown time zone %q", name)
+	}
+	return nil
+}
+
+// itemLocation возвращает часовой пояс объекта: свой, ближайшего узла иерархии или сервера БД
+func itemLocation(q queryer, itemID string) (*time.Location, error) {
+	if _, err := uuid.Parse(itemID); err != nil {
+		return nil, &bookingError{Status: http.StatusNotFound, Message: "Item not found"}
+	}
+	var zone sql.NullString
+	if err := q.QueryRow("SELECT item_time_zone($1::uuid)", itemID).Scan(&zone); err != nil {
+		return nil, err
+	}
+	if !zone.Valid {
+		return nil, &bookingError{Status: http.StatusNotFound, Message: "Item not found"}
+	}
+	return loadZone(zone.String), nil
+}
+
+// userLocation возвращает пояс, в котором пользователю показываются времена; без настройки — пояс сервера БД
+func userLocation(q queryer, userID string) *time.Location {
+	var zone sql.NullString
+	if err := q.QueryRow("SELECT time_zone FROM users WHERE id = $1", userID).Scan(&zone); err != nil {
+		return models.DBLocation
+	}
+	return loadZone(zone.String)
+}
+
+// setSlotInstants заполняет моменты начала и конца слота в RFC 3339 в поясе объекта zone
+func setSlotInstants(slot *models.BookingSlot, startsAt, endsAt time.Time, zone string) {
+	loc := loadZone(zone)
+	slot.StartsAt = startsAt.In(loc).Format(time.RFC3339)
+	slot.EndsAt = endsAt.In(loc).Format(time.RFC3339)
+	slot.TimeZone = loc.String()
+}
+
+// parseDateTimeIn разбирает момент времени в RFC 3339 или «настенное» время "2006-01-02T15:04[:05]" в поясе loc.
+// Для даты без времени возвращается полночь; если endOfDay, то полночь следующего дня
+func parseDateTimeIn(s string, endOfDay bool, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date-time %q", s)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
@@ -4,6 +4,7 @@ import (
 	"booking-system/models"
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -19,21 +20,25 @@ func UserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var user models.User
-	err := models.DB.QueryRow("SELECT id, login, full_name, birth_date, gender FROM users WHERE id = $1", userID).
-		Scan(&user.ID, &user.Login, &user.FullName, &user.BirthDate, &user.Gender)
+	err := models.DB.QueryRow("SELECT id, login, full_name, birth_date, gender, COALESCE(time_zone, '') FROM users WHERE id = $1", userID).
+		Scan(&user.ID, &user.Login, &user.FullName, &user.BirthDate, &user.Gender, &user.TimeZone)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	rows, err := models.DB.Query(`
-		SELECT b.id, b.created_at, bs.date, bs.start_time, bs.end_time, bi.name 
+		SELECT b.id, b.created_at, bs.date, bs.start_time, `+bookingEndTimeSQL+`, bi.name, b.status, b.reject_reason,
+		       b.status = 'confirmed' AND b.checked_in_at IS NULL
+		       AND NOW() >= `+slotStartsAtSQL+` - make_interval(mins => $2)
+		       AND NOW() < LEAST(`+bookingEndsAtSQL+`, `+slotStartsAtSQL+` + make_interval(mins => $3)),
+		       bs.starts_at, bi.free_cancel_hours, bi.late_cancel_action
 		FROM bookings b
 		JOIN booking_slots bs ON b.slot_id = bs.id
 		JOIN booking_items bi ON bs.item_id = bi.id
 		WHERE b.user_id = $1
 		ORDER BY bs.date, bs.start_time
-	`, userID)
+	`, userID, models.CheckInWindowMinutes, models.NoShowGraceMinutes)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -41,28 +46,76 @@ func UserHandler(w http.ResponseWriter, r *http.Request) {
 	defer rows.Close()
 
 	type BookingView struct {
-		ID        string
-		CreatedAt string
-		Date      string
-		StartTime string
-		EndTime   string
-		ItemName  string
+		ID           string
+		CreatedAt    string
+		Date         string
+		StartTime    string
+		EndTime      string
+		ItemName     string
+		Status       string
+		RejectReason *string
+		CanCheckIn   bool
+		Cancellable  bool
+		LateCancel   bool
 	}
 
+	now := time.Now()
 	var bookings []BookingView
 	for rows.Next() {
 		var b BookingView
-		rows.Scan(&b.ID, &b.CreatedAt, &b.Date, &b.StartTime, &b.EndTime, &b.ItemName)
+		var start time.Time
+		var freeCancelHours *int
+		var lateCancelAction *string
+		rows.Scan(&b.ID, &b.CreatedAt, &b.Date, &b.StartTime, &b.EndTime, &b.ItemName, &b.Status, &b.RejectReason,
+			&b.CanCheckIn, &start, &freeCancelHours, &lateCancelAction)
+		check := checkCancellation(itemCancellationPolicy(freeCancelHours, lateCancelAction), b.Status, start, now)
+		b.Cancellable, b.LateCancel = check.Cancellable, check.Late
 		bookings = append(bookings, b)
 	}
 
+	// Бронирования на сегодня считаются так же, как для дневного лимита: по дате слота в поясе его объекта
 	var bookingCount int
-	models.DB.QueryRow("SELECT COUNT(*) FROM bookings WHERE user_id = $1", userID).Scan(&bookingCount)
+	models.DB.QueryRow(`
+		SELECT COUNT(DISTINCT COALESCE(b.bundle_booking_id, b.id))
+		FROM bookings b
+		JOIN booking_slots bs ON b.slot_id = bs.id
+		WHERE b.user_id = $1 AND b.status NOT IN ('cancelled', 'rejected')
+		  AND bs.date = (NOW() AT TIME ZONE item_time_zone(bs.item_id))::date
+	`, userID).Scan(&bookingCount)
+
+	noShowCount, err := countRecentNoShows(models.DB, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var penaltyCount int
+	models.DB.QueryRow("SELECT COUNT(*) FROM cancellation_penalties WHERE user_id = $1", userID).Scan(&penaltyCount)
+
+	waitlist, err := loadUserWaitlist(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	notifications, err := loadNotifications(userID, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	models.Tmpl.ExecuteTemplate(w, "user.html", map[string]interface{}{
-		"User":         user,
-		"Bookings":     bookings,
-		"BookingCount": bookingCount,
+		"User":             user,
+		"Bookings":         bookings,
+		"BookingCount":     bookingCount,
+		"MaxDailyBookings": models.MaxDailyBookings,
+		"NoShowCount":      noShowCount,
+		"NoShowLimit":      models.NoShowLimit,
+		"NoShowWindowDays": models.NoShowWindowDays,
+		"PenaltyCount":     penaltyCount,
+		"FreeCancelHours":  models.FreeCancelHours,
+		"Waitlist":         waitlist,
+		"Notifications":    notifications,
 	})
 }
 
@@ -92,6 +145,7 @@ func ApiCreateUserHandler(w http.ResponseWriter, r *http.Request) {
 		BirthDate string `json:"birth_date"`
 		Gender    string `json:"gender"`
 		Role      string `json:"role"`
+		TimeZone  string `json:"time_zone"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&newUser); err != nil {
@@ -115,6 +169,11 @@ func ApiCreateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := validateTimeZone(newUser.TimeZone); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	if role == "manager" {
 		newUser.Role = "user"
 	} else if newUser.Role == "" {
@@ -137,10 +196,10 @@ func ApiCreateUserHandler(w http.ResponseWriter, r *http.Request) {
 	defer tx.Rollback()
 
 	_, err = tx.Exec(`
-        INSERT INTO users (id, login, password, full_name, birth_date, gender, role) 
-        VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+        INSERT INTO users (id, login, password, full_name, birth_date, gender, role, time_zone) 
+        VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''))`,
 		userID, newUser.Login, string(hashedPassword), newUser.FullName,
-		newUser.BirthDate, newUser.Gender, newUser.Role)
+		newUser.BirthDate, newUser.Gender, newUser.Role, newUser.TimeZone)
 	if err != nil {
 		http.Error(w, "Error creating user", http.StatusInternalServerError)
 		return
@@ -164,6 +223,37 @@ func ApiCreateUserHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// ApiUpdateMyTimeZoneHandler задаёт пояс, в котором текущему пользователю показываются времена бронирований.
+// Пустая строка возвращает пояс сервера БД
+func ApiUpdateMyTimeZoneHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	userID, ok := session.Values["user_id"].(string)
+	if !ok {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		TimeZone string `json:"time_zone"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request data"})
+		return
+	}
+	if err := validateTimeZone(req.TimeZone); err != nil {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	_, err := models.DB.Exec("UPDATE users SET time_zone = NULLIF($2, '') WHERE id = $1", userID, req.TimeZone)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"time_zone": userLocation(models.DB, userID).String()})
+}
+
 func ApiDeleteUserHandler(w http.ResponseWriter, r *http.Request) {
 	session, _ := models.Store.Get(r, "session")
 	role, ok := session.Values["role"].(string)
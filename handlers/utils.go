@@ -8,8 +8,19 @@ import (
 )
 
 func LoadSystemSettings() {
-	row := models.DB.QueryRow("SELECT slot_duration_minutes, day_start_time, day_end_time FROM system_settings LIMIT 1")
-	err := row.Scan(&models.SlotDuration, &models.DayStart, &models.DayEnd)
+	row := models.DB.QueryRow(`
+		SELECT slot_duration_minutes, day_start_time, day_end_time,
+		       booking_window_days, min_booking_hours, max_daily_bookings,
+		       waitlist_mode, waitlist_offer_minutes, hold_minutes,
+		       check_in_required, check_in_window_minutes, no_show_grace_minutes, no_show_limit, no_show_window_days,
+		       free_cancel_hours, late_cancel_action
+		FROM system_settings LIMIT 1
+	`)
+	err := row.Scan(&models.SlotDuration, &models.DayStart, &models.DayEnd,
+		&models.BookingWindowDays, &models.MinBookingHours, &models.MaxDailyBookings,
+		&models.WaitlistMode, &models.WaitlistOfferMinutes, &models.HoldMinutes,
+		&models.CheckInRequired, &models.CheckInWindowMinutes, &models.NoShowGraceMinutes, &models.NoShowLimit, &models.NoShowWindowDays,
+		&models.FreeCancelHours, &models.LateCancelAction)
 	if err != nil {
 		log.Println("Using default system settings:", err)
 	}
@@ -0,0 +1,427 @@
+package handlers
+
+import (
+	"booking-system/models"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// waitlistPositionSQL — позиция записи wl среди ожидающих того же слота
+const waitlistPositionSQL = `(
+	SELECT COUNT(*) FROM waitlist_entries w2
+	WHERE w2.slot_id = wl.slot_id AND w2.status = 'waiting' AND w2.created_at <= wl.created_at
+)`
+
+// loadUserWaitlist возвращает активные записи пользователя в листах ожидания.
+// Срок предложения возвращается в RFC 3339 в поясе пользователя
+func loadUserWaitlist(userID string) ([]models.WaitlistEntry, error) {
+	loc := userLocation(models.DB, userID)
+
+	rows, err := models.DB.Query(`
+		SELECT wl.id, wl.slot_id, wl.participants, wl.status,
+		       CASE WHEN wl.status = 'waiting' THEN `+waitlistPositionSQL+` ELSE 0 END,
+		       wl.offer_expires_at,
+		       to_char(bs.date, 'YYYY-MM-DD'), bs.start_time::text, bs.end_time::text, bi.name, wl.created_at
+		FROM waitlist_entries wl
+		JOIN booking_slots bs ON wl.slot_id = bs.id
+		JOIN booking_items bi ON bs.item_id = bi.id
+		WHERE wl.user_id = $1 AND wl.status IN ('waiting', 'offered')
+		ORDER BY bs.date, bs.start_time
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.WaitlistEntry{}
+	for rows.Next() {
+		var e models.WaitlistEntry
+		var offerExpiresAt *time.Time
+		if err := rows.Scan(&e.ID, &e.SlotID, &e.Participants, &e.Status, &e.Position, &offerExpiresAt,
+			&e.Date, &e.StartTime, &e.EndTime, &e.ItemName, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if offerExpiresAt != nil {
+			expires := offerExpiresAt.In(loc).Format(time.RFC3339)
+			e.OfferExpiresAt = &expires
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// promoteWaitlist раздаёт освободившиеся места слота ожидающим в порядке очереди.
+// В режиме 'auto' подходящий пользователь сразу получает бронирование, в режиме 'offer' —
+// предложение, которое нужно подтвердить в течение waitlist_offer_minutes.
+// Пользователи, которым не хватает мест или которые нарушают правила бронирования, остаются в очереди
+func promoteWaitlist(tx *sql.Tx, slotID string) error {
+	var date, startTime, itemName string
+	err := tx.QueryRow(`
+		SELECT to_char(bs.date, 'YYYY-MM-DD'), bs.start_time::text, bi.name
+		FROM booking_slots bs
+		JOIN booking_items bi ON bs.item_id = bi.id
+		WHERE bs.id = $1 FOR UPDATE OF bs
+	`, slotID).Scan(&date, &startTime, &itemName)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`
+		SELECT id, user_id, participants
+		FROM waitlist_entries
+		WHERE slot_id = $1 AND status = 'waiting'
+		ORDER BY created_at
+		FOR UPDATE
+	`, slotID)
+	if err != nil {
+		return err
+	}
+
+	type waiter struct {
+		ID           string
+		UserID       string
+		Participants int
+	}
+	var waiters []waiter
+	for rows.Next() {
+		var wt waiter
+		if err := rows.Scan(&wt.ID, &wt.UserID, &wt.Participants); err != nil {
+			rows.Close()
+			return err
+		}
+		waiters = append(waiters, wt)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, wt := range waiters {
+		var isOpen bool
+		var remaining int
+		err := tx.QueryRow(`SELECT `+slotOpenSQL+`, `+remainingSeatsSQL+` FROM booking_slots bs WHERE bs.id = $1`, slotID).
+			Scan(&isOpen, &remaining)
+		if err != nil {
+			return err
+		}
+		if !isOpen || remaining == 0 {
+			return nil
+		}
+		if remaining < wt.Participants {
+			continue
+		}
+
+		if models.WaitlistMode == "offer" {
+			violations, err := checkBookingPolicy(tx, wt.UserID, slotID, "")
+			if err != nil {
+				return err
+			}
+			if len(violations) > 0 {
+				continue
+			}
+
+			_, err = tx.Exec(`
+				UPDATE waitlist_entries
+				SET status = 'offered', offer_expires_at = NOW() + make_interval(mins => $2), updated_at = NOW()
+				WHERE id = $1
+			`, wt.ID, models.WaitlistOfferMinutes)
+			if err != nil {
+				return err
+			}
+
+			message := fmt.Sprintf("A place on %s on %s at %s is available for you. Claim it within %d minutes.",
+				itemName, date, startTime, models.WaitlistOfferMinutes)
+			if err := notifyUser(tx, wt.UserID, message); err != nil {
+				return err
+			}
+			continue
+		}
+
+		bookingID, err := bookSlot(tx, wt.UserID, slotID, wt.Participants)
+		var be *bookingError
+		if errors.As(err, &be) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			UPDATE waitlist_entries SET status = 'booked', booking_id = $2, updated_at = NOW()
+			WHERE id = $1
+		`, wt.ID, bookingID)
+		if err != nil {
+			return err
+		}
+
+		message := fmt.Sprintf("You have been booked from the waitlist: %s on %s at %s.", itemName, date, startTime)
+		if err := notifyUser(tx, wt.UserID, message); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// expireWaitlist истекает просроченные предложения и записи на прошедшие слоты,
+// после чего передаёт освободившиеся места следующим в очереди
+func expireWaitlist() error {
+	tx, err := models.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		UPDATE waitlist_entries SET status = 'expired', updated_at = NOW()
+		WHERE status = 'offered' AND offer_expires_at <= NOW()
+		RETURNING slot_id
+	`)
+	if err != nil {
+		return err
+	}
+
+	slots := make(map[string]bool)
+	for rows.Next() {
+		var slotID string
+		rows.Scan(&slotID)
+		slots[slotID] = true
+	}
+	rows.Close()
+
+	_, err = tx.Exec(`
+		UPDATE waitlist_entries wl SET status = 'expired', updated_at = NOW()
+		FROM booking_slots bs
+		WHERE wl.slot_id = bs.id AND wl.status = 'waiting' AND ` + slotStartsAtSQL + ` <= NOW()
+	`)
+	if err != nil {
+		return err
+	}
+
+	for slotID := range slots {
+		if err := promoteWaitlist(tx, slotID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func ApiJoinWaitlistHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	userID, ok := session.Values["user_id"].(string)
+	if !ok {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	vars := mux.Vars(r)
+	slotID := vars["id"]
+
+	var req struct {
+		Participants int `json:"participants"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	if req.Participants == 0 {
+		req.Participants = 1
+	}
+	if req.Participants < 0 {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Participants must be positive"})
+		return
+	}
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	var lockedID string
+	err = tx.QueryRow("SELECT id FROM booking_slots WHERE id = $1 FOR UPDATE", slotID).Scan(&lockedID)
+	if err != nil {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Slot not found"})
+		return
+	}
+
+	var isOpen, started bool
+	var remaining, maxParticipants int
+	err = tx.QueryRow(`
+		SELECT `+slotOpenSQL+`, `+remainingSeatsSQL+`, bs.max_participants, `+slotStartsAtSQL+` <= NOW()
+		FROM booking_slots bs WHERE bs.id = $1
+	`, slotID).Scan(&isOpen, &remaining, &maxParticipants, &started)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if !isOpen || started {
+		respondWithJSON(w, http.StatusConflict, map[string]string{"error": "Slot is not available"})
+		return
+	}
+	if req.Participants > maxParticipants {
+		respondWithJSON(w, http.StatusBadRequest, map[string]string{"error": "Slot cannot fit that many participants"})
+		return
+	}
+	if remaining >= req.Participants {
+		respondWithJSON(w, http.StatusConflict, map[string]string{"error": "Slot has free seats, book it directly"})
+		return
+	}
+
+	var entryID string
+	var position int
+	err = tx.QueryRow(`
+		INSERT INTO waitlist_entries (user_id, slot_id, participants)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, slot_id) WHERE status IN ('waiting', 'offered') DO NOTHING
+		RETURNING id
+	`, userID, slotID, req.Participants).Scan(&entryID)
+	if err == sql.ErrNoRows {
+		respondWithJSON(w, http.StatusConflict, map[string]string{"error": "Already on the waitlist for this slot"})
+		return
+	}
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	err = tx.QueryRow(`SELECT `+waitlistPositionSQL+` FROM waitlist_entries wl WHERE wl.id = $1`, entryID).
+		Scan(&position)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"id":       entryID,
+		"position": position,
+	})
+}
+
+func ApiLeaveWaitlistHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	userID, ok := session.Values["user_id"].(string)
+	if !ok {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	vars := mux.Vars(r)
+	entryID := vars["id"]
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	var slotID, status string
+	err = tx.QueryRow(`
+		SELECT slot_id, status FROM waitlist_entries
+		WHERE id = $1 AND user_id = $2 AND status IN ('waiting', 'offered')
+		FOR UPDATE
+	`, entryID, userID).Scan(&slotID, &status)
+	if err != nil {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "Waitlist entry not found"})
+		return
+	}
+
+	_, err = tx.Exec("UPDATE waitlist_entries SET status = 'cancelled', updated_at = NOW() WHERE id = $1", entryID)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Отказ от предложения освобождает места для следующих в очереди
+	if status == "offered" {
+		if err := promoteWaitlist(tx, slotID); err != nil {
+			respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func ApiClaimWaitlistOfferHandler(w http.ResponseWriter, r *http.Request) {
+	session, _ := models.Store.Get(r, "session")
+	userID, ok := session.Values["user_id"].(string)
+	if !ok {
+		respondWithJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	vars := mux.Vars(r)
+	entryID := vars["id"]
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	var slotID string
+	var participants int
+	err = tx.QueryRow(`
+		SELECT slot_id, participants FROM waitlist_entries
+		WHERE id = $1 AND user_id = $2 AND status = 'offered' AND offer_expires_at > NOW()
+		FOR UPDATE
+	`, entryID, userID).Scan(&slotID, &participants)
+	if err != nil {
+		respondWithJSON(w, http.StatusNotFound, map[string]string{"error": "No active offer found"})
+		return
+	}
+
+	// Сначала снимаем предложение, чтобы его места не считались занятыми при бронировании
+	_, err = tx.Exec("UPDATE waitlist_entries SET status = 'booked', updated_at = NOW() WHERE id = $1", entryID)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	bookingID, err := bookSlot(tx, userID, slotID, participants)
+	if err != nil {
+		respondBookingError(w, err)
+		return
+	}
+
+	_, err = tx.Exec("UPDATE waitlist_entries SET booking_id = $2 WHERE id = $1", entryID, bookingID)
+	if err != nil {
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Waitlist claim commit failed: %v", err)
+		respondWithJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]string{"id": bookingID})
+}
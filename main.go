@@ -31,6 +31,7 @@ func initDB() {
 	}
 
 	handlers.LoadSystemSettings()
+	handlers.LoadDBTimeZone()
 }
 
 func main() {
@@ -59,32 +60,107 @@ func main() {
 	r.HandleFunc("/api/login", handlers.ApiLoginHandler).Methods("POST")
 	r.HandleFunc("/api/users", handlers.ApiCreateUserHandler).Methods("POST")
 	r.HandleFunc("/api/users/{id}", handlers.ApiDeleteUserHandler).Methods("DELETE")
+	r.HandleFunc("/api/me/time-zone", handlers.ApiUpdateMyTimeZoneHandler).Methods("PUT")
 
 	// API маршруты для объектов бронирования
+	r.HandleFunc("/api/booking-items", handlers.ApiListBookingItemsHandler).Methods("GET")
 	r.HandleFunc("/api/booking-items", handlers.ApiCreateBookingItemHandler).Methods("POST")
+	r.HandleFunc("/api/booking-items/{id}", handlers.ApiGetBookingItemHandler).Methods("GET")
+	r.HandleFunc("/api/booking-items/{id}", handlers.ApiReplaceBookingItemHandler).Methods("PUT")
+	r.HandleFunc("/api/booking-items/{id}", handlers.ApiPatchBookingItemHandler).Methods("PATCH")
 	r.HandleFunc("/api/booking-items/{id}", handlers.ApiDeleteBookingItemHandler).Methods("DELETE")
+	r.HandleFunc("/api/booking-items/{id}/approval", handlers.ApiUpdateItemApprovalHandler).Methods("PUT")
+	r.HandleFunc("/api/booking-items/{id}/cancellation-policy", handlers.ApiUpdateItemCancellationPolicyHandler).Methods("PUT")
+	r.HandleFunc("/api/cancellation-policy", handlers.ApiGetCancellationPolicyHandler).Methods("GET")
+
+	// API маршруты для иерархии размещения объектов
+	r.HandleFunc("/api/locations", handlers.ApiGetLocationsHandler).Methods("GET")
+	r.HandleFunc("/api/locations", handlers.ApiCreateLocationHandler).Methods("POST")
+	r.HandleFunc("/api/locations/{id}", handlers.ApiUpdateLocationHandler).Methods("PATCH")
+	r.HandleFunc("/api/locations/{id}", handlers.ApiDeleteLocationHandler).Methods("DELETE")
+	r.HandleFunc("/api/locations/{id}/hours", handlers.ApiGetLocationHoursHandler).Methods("GET")
+	r.HandleFunc("/api/locations/{id}/hours", handlers.ApiUpdateLocationHoursHandler).Methods("PUT")
+	r.HandleFunc("/api/locations/{id}/hours", handlers.ApiDeleteLocationHoursHandler).Methods("DELETE")
+	r.HandleFunc("/api/locations/{id}/managers", handlers.ApiUpdateLocationManagersHandler).Methods("PUT")
 
 	// API маршруты для слотов бронирования
 	r.HandleFunc("/api/booking-slots", handlers.ApiGetAvailableSlotsHandler).Methods("GET")
 	r.HandleFunc("/api/booking-slots/{id}/book", handlers.ApiBookSlotHandler).Methods("POST")
 	r.HandleFunc("/api/booking-slots/{id}/cancel", handlers.ApiCancelBookingHandler).Methods("POST")
 	r.HandleFunc("/api/booking-slots/{id}/block", handlers.ApiBlockSlotHandler).Methods("POST")
+	r.HandleFunc("/api/booking-slots/{id}/waitlist", handlers.ApiJoinWaitlistHandler).Methods("POST")
+	r.HandleFunc("/api/booking-slots/{id}/hold", handlers.ApiCreateHoldHandler).Methods("POST")
 	r.HandleFunc("/api/bookings", handlers.ApiGetUserBookingsHandler).Methods("GET")
+	r.HandleFunc("/api/bookings/range", handlers.ApiBookRangeHandler).Methods("POST")
+	r.HandleFunc("/api/bookings/{id}/reschedule", handlers.ApiRescheduleBookingHandler).Methods("POST")
+	r.HandleFunc("/api/bookings/{id}/check-in", handlers.ApiCheckInHandler).Methods("POST")
 	r.HandleFunc("/api/available-dates", handlers.ApiGetAvailableDatesHandler).Methods("GET")
+	r.HandleFunc("/api/calendar", handlers.ApiGetCalendarHandler).Methods("GET")
+	r.HandleFunc("/api/freebusy", handlers.ApiGetFreeBusyHandler).Methods("GET")
+	r.HandleFunc("/api/freebusy/common", handlers.ApiFindCommonFreeTimeHandler).Methods("GET")
+	r.HandleFunc("/api/search/availability", handlers.ApiSearchAvailabilityHandler).Methods("GET")
+
+	// API маршруты для комплектов объектов
+	r.HandleFunc("/api/bundles", handlers.ApiGetBundlesHandler).Methods("GET")
+	r.HandleFunc("/api/bundles", handlers.ApiCreateBundleHandler).Methods("POST")
+	r.HandleFunc("/api/bundles/{id}", handlers.ApiUpdateBundleHandler).Methods("PUT")
+	r.HandleFunc("/api/bundles/{id}", handlers.ApiDeleteBundleHandler).Methods("DELETE")
+	r.HandleFunc("/api/bundles/{id}/availability", handlers.ApiGetBundleAvailabilityHandler).Methods("GET")
+	r.HandleFunc("/api/bundles/{id}/book", handlers.ApiBookBundleHandler).Methods("POST")
+
+	// API маршруты для серий повторяющихся бронирований
+	r.HandleFunc("/api/booking-series", handlers.ApiGetBookingSeriesHandler).Methods("GET")
+	r.HandleFunc("/api/booking-series", handlers.ApiCreateBookingSeriesHandler).Methods("POST")
+	r.HandleFunc("/api/booking-series/{id}", handlers.ApiCancelBookingSeriesHandler).Methods("DELETE")
+
+	// API маршруты для временных удержаний слотов
+	r.HandleFunc("/api/holds/{id}/confirm", handlers.ApiConfirmHoldHandler).Methods("POST")
+	r.HandleFunc("/api/holds/{id}", handlers.ApiReleaseHoldHandler).Methods("DELETE")
+
+	// API маршруты для листа ожидания
+	r.HandleFunc("/api/waitlist/{id}", handlers.ApiLeaveWaitlistHandler).Methods("DELETE")
+	r.HandleFunc("/api/waitlist/{id}/claim", handlers.ApiClaimWaitlistOfferHandler).Methods("POST")
+
+	// API маршруты менеджерской консоли бронирований
+	r.HandleFunc("/api/manager/bookings", handlers.ApiManagerListBookingsHandler).Methods("GET")
+	r.HandleFunc("/api/manager/bookings", handlers.ApiManagerCreateBookingHandler).Methods("POST")
+	r.HandleFunc("/api/manager/bookings/{id}/cancel", handlers.ApiManagerCancelBookingHandler).Methods("POST")
+	r.HandleFunc("/api/manager/bookings/{id}/check-in", handlers.ApiManagerCheckInHandler).Methods("POST")
+	r.HandleFunc("/api/manager/approvals", handlers.ApiApprovalQueueHandler).Methods("GET")
+	r.HandleFunc("/api/manager/bookings/{id}/approve", handlers.ApiApproveBookingHandler).Methods("POST")
+	r.HandleFunc("/api/manager/bookings/{id}/reject", handlers.ApiRejectBookingHandler).Methods("POST")
 
 	// API маршруты для управления слотами объектов бронирования
 	r.HandleFunc("/api/items/{id}/slots", handlers.ApiGetItemSlotsHandler).Methods("GET")
 	r.HandleFunc("/api/items/{id}/slots", handlers.ApiUpdateItemSlotsHandler).Methods("PUT")
+	r.HandleFunc("/api/items/{id}/slots/generate", handlers.ApiGenerateSlotsHandler).Methods("POST")
+	r.HandleFunc("/api/items/{id}/week", handlers.ApiGetItemWeekHandler).Methods("GET")
+	r.HandleFunc("/api/items/{id}/schedule", handlers.ApiGetItemScheduleHandler).Methods("GET")
+	r.HandleFunc("/api/items/{id}/schedule", handlers.ApiUpdateItemScheduleHandler).Methods("PUT")
+	r.HandleFunc("/api/items/{id}/schedule", handlers.ApiDeleteItemScheduleHandler).Methods("DELETE")
 	r.HandleFunc("/api/slots", handlers.ApiCreateSlotHandler).Methods("POST")
+	r.HandleFunc("/api/slots/overlaps", handlers.ApiGetSlotOverlapsHandler).Methods("GET")
 	r.HandleFunc("/api/slots/{id}", handlers.ApiDeleteSlotHandler).Methods("DELETE")
 
 	// API маршруты для настроек и управления датами
 	r.HandleFunc("/api/settings", handlers.ApiUpdateSettingsHandler).Methods("POST")
 	r.HandleFunc("/api/dates/{date}/availability", handlers.ApiToggleDateAvailabilityHandler).Methods("POST")
 
+	// API маршруты для закрытий (праздники, обслуживание, мероприятия)
+	r.HandleFunc("/api/closures", handlers.ApiGetClosuresHandler).Methods("GET")
+	r.HandleFunc("/api/closures", handlers.ApiCreateClosureHandler).Methods("POST")
+	r.HandleFunc("/api/closures/{id}/conflicts", handlers.ApiGetClosureConflictsHandler).Methods("GET")
+	r.HandleFunc("/api/closures/{id}", handlers.ApiDeleteClosureHandler).Methods("DELETE")
+
+	// API маршруты для уведомлений
+	r.HandleFunc("/api/notifications", handlers.ApiGetNotificationsHandler).Methods("GET")
+	r.HandleFunc("/api/notifications/{id}/read", handlers.ApiMarkNotificationReadHandler).Methods("POST")
+
 	// Middleware для проверки аутентификации
 	r.Use(handlers.AuthMiddleware)
 
+	handlers.StartBackgroundJobs()
+
 	log.Println("Server started on :8080")
 	log.Fatal(http.ListenAndServe(":8080", r))
 }
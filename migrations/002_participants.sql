@@ -0,0 +1,6 @@
+-- Количество участников бронирования и вместимость слота должны быть положительными
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_participants_check;
+ALTER TABLE bookings ADD CONSTRAINT bookings_participants_check CHECK (participants > 0);
+
+ALTER TABLE booking_slots DROP CONSTRAINT IF EXISTS booking_slots_max_participants_check;
+ALTER TABLE booking_slots ADD CONSTRAINT booking_slots_max_participants_check CHECK (max_participants > 0);
@@ -0,0 +1,23 @@
+-- Недельное расписание работы объектов бронирования.
+-- Если у объекта нет ни одной записи, используются глобальные system_settings;
+-- день недели, отсутствующий в расписании объекта, считается выходным
+CREATE TABLE IF NOT EXISTS item_schedule_days (
+    item_id UUID NOT NULL REFERENCES booking_items(id) ON DELETE CASCADE,
+    weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),  -- 0 = воскресенье
+    slot_duration_minutes INTEGER CHECK (slot_duration_minutes > 0),
+    PRIMARY KEY (item_id, weekday)
+);
+
+-- Интервалы работы и перерывы внутри дня недели
+CREATE TABLE IF NOT EXISTS item_schedule_intervals (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    item_id UUID NOT NULL,
+    weekday SMALLINT NOT NULL,
+    start_time TIME NOT NULL,
+    end_time TIME NOT NULL,
+    is_break BOOLEAN NOT NULL DEFAULT FALSE,
+    FOREIGN KEY (item_id, weekday) REFERENCES item_schedule_days(item_id, weekday) ON DELETE CASCADE,
+    CONSTRAINT valid_schedule_interval CHECK (end_time > start_time)
+);
+
+CREATE INDEX IF NOT EXISTS idx_item_schedule_intervals_item ON item_schedule_intervals(item_id, weekday);
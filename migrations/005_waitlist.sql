@@ -0,0 +1,23 @@
+-- Лист ожидания для полностью занятых слотов
+CREATE TABLE IF NOT EXISTS waitlist_entries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    slot_id UUID NOT NULL REFERENCES booking_slots(id) ON DELETE CASCADE,
+    participants INTEGER NOT NULL DEFAULT 1 CHECK (participants > 0),
+    status TEXT NOT NULL DEFAULT 'waiting' CHECK (status IN ('waiting', 'offered', 'booked', 'expired', 'cancelled')),
+    offer_expires_at TIMESTAMP,
+    booking_id UUID REFERENCES bookings(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Пользователь может стоять в очереди на слот только один раз
+CREATE UNIQUE INDEX IF NOT EXISTS idx_waitlist_active
+    ON waitlist_entries(user_id, slot_id) WHERE status IN ('waiting', 'offered');
+CREATE INDEX IF NOT EXISTS idx_waitlist_slot_id ON waitlist_entries(slot_id, created_at);
+
+-- Режим продвижения очереди: 'auto' — сразу бронировать, 'offer' — предложить слот на время
+ALTER TABLE system_settings ADD COLUMN IF NOT EXISTS waitlist_mode TEXT NOT NULL DEFAULT 'auto'
+    CHECK (waitlist_mode IN ('auto', 'offer'));
+ALTER TABLE system_settings ADD COLUMN IF NOT EXISTS waitlist_offer_minutes INTEGER NOT NULL DEFAULT 30
+    CHECK (waitlist_offer_minutes > 0);
@@ -0,0 +1,10 @@
+-- Отмена бронирования меняет статус вместо удаления строки
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMP;
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS cancel_reason TEXT;
+
+-- После отмены пользователь может снова забронировать тот же слот
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_user_id_slot_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bookings_active_user_slot
+    ON bookings(user_id, slot_id) WHERE status <> 'cancelled';
+
+CREATE INDEX IF NOT EXISTS idx_bookings_status ON bookings(status);
@@ -0,0 +1,18 @@
+-- Серии повторяющихся бронирований
+CREATE TABLE IF NOT EXISTS booking_series (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    item_id UUID NOT NULL REFERENCES booking_items(id) ON DELETE CASCADE,
+    rule TEXT NOT NULL,
+    start_date DATE NOT NULL,
+    start_time TIME NOT NULL,
+    participants INTEGER NOT NULL DEFAULT 1 CHECK (participants > 0),
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'cancelled')),
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_booking_series_user_id ON booking_series(user_id);
+
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS series_id UUID REFERENCES booking_series(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_bookings_series_id ON bookings(series_id);
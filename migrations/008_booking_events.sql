@@ -0,0 +1,12 @@
+-- История изменений бронирований (переносы и т.п.)
+CREATE TABLE IF NOT EXISTS booking_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    booking_id UUID NOT NULL REFERENCES bookings(id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    from_slot_id UUID REFERENCES booking_slots(id) ON DELETE SET NULL,
+    to_slot_id UUID REFERENCES booking_slots(id) ON DELETE SET NULL,
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_booking_events_booking_id ON booking_events(booking_id);
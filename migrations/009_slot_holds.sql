@@ -0,0 +1,15 @@
+-- Временные удержания мест в слоте на время оформления бронирования
+CREATE TABLE IF NOT EXISTS slot_holds (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    slot_id UUID NOT NULL REFERENCES booking_slots(id) ON DELETE CASCADE,
+    participants INTEGER NOT NULL DEFAULT 1 CHECK (participants > 0),
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_slot_holds_slot_id ON slot_holds(slot_id, expires_at);
+CREATE INDEX IF NOT EXISTS idx_slot_holds_user_id ON slot_holds(user_id);
+
+ALTER TABLE system_settings ADD COLUMN IF NOT EXISTS hold_minutes INTEGER NOT NULL DEFAULT 10
+    CHECK (hold_minutes > 0);
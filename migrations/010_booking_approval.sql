@@ -0,0 +1,34 @@
+-- Объекты, бронирования которых требуют подтверждения менеджера.
+-- pending_holds_seats определяет, занимает ли ожидающая заявка места в слоте
+ALTER TABLE booking_items ADD COLUMN IF NOT EXISTS requires_approval BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE booking_items ADD COLUMN IF NOT EXISTS pending_holds_seats BOOLEAN NOT NULL DEFAULT true;
+
+-- Миграции выполняются при каждом запуске, поэтому ограничение и индекс пересоздаются,
+-- только пока не знают о 'rejected': иначе повторный запуск сбросил бы статусы, добавленные позже
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'bookings_status_check' AND pg_get_constraintdef(oid) LIKE '%rejected%'
+    ) THEN
+        ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_status_check;
+        ALTER TABLE bookings ADD CONSTRAINT bookings_status_check
+            CHECK (status IN ('pending', 'confirmed', 'rejected', 'cancelled', 'completed'));
+    END IF;
+END
+$$;
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS reject_reason TEXT;
+
+-- Отклонённая заявка, как и отменённая, не мешает забронировать слот повторно
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_indexes
+        WHERE indexname = 'idx_bookings_active_user_slot' AND indexdef LIKE '%rejected%'
+    ) THEN
+        DROP INDEX IF EXISTS idx_bookings_active_user_slot;
+        CREATE UNIQUE INDEX idx_bookings_active_user_slot
+            ON bookings(user_id, slot_id) WHERE status NOT IN ('cancelled', 'rejected');
+    END IF;
+END
+$$;
@@ -0,0 +1,33 @@
+-- Отметка о приходе и неявки
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS checked_in_at TIMESTAMP;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'bookings_status_check' AND pg_get_constraintdef(oid) LIKE '%no_show%'
+    ) THEN
+        ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_status_check;
+        ALTER TABLE bookings ADD CONSTRAINT bookings_status_check
+            CHECK (status IN ('pending', 'confirmed', 'rejected', 'cancelled', 'completed', 'no_show'));
+    END IF;
+END
+$$;
+
+-- check_in_required — фиксировать ли неявки (по умолчанию выключено); check_in_required_since — момент включения:
+-- неявки фиксируются только по бронированиям, созданным после него, остальные завершаются как обычно.
+-- check_in_window_minutes — за сколько минут до начала слота открывается самостоятельная отметка,
+-- no_show_grace_minutes — сколько минут после начала ждать отметки до фиксации неявки,
+-- no_show_limit — после скольких неявок за no_show_window_days бронирование приостанавливается (0 — без ограничения)
+ALTER TABLE system_settings ADD COLUMN IF NOT EXISTS check_in_required BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE system_settings ADD COLUMN IF NOT EXISTS check_in_required_since TIMESTAMP;
+ALTER TABLE system_settings ADD COLUMN IF NOT EXISTS check_in_window_minutes INTEGER NOT NULL DEFAULT 15
+    CHECK (check_in_window_minutes >= 0);
+ALTER TABLE system_settings ADD COLUMN IF NOT EXISTS no_show_grace_minutes INTEGER NOT NULL DEFAULT 15
+    CHECK (no_show_grace_minutes >= 0);
+ALTER TABLE system_settings ADD COLUMN IF NOT EXISTS no_show_limit INTEGER NOT NULL DEFAULT 3
+    CHECK (no_show_limit >= 0);
+ALTER TABLE system_settings ADD COLUMN IF NOT EXISTS no_show_window_days INTEGER NOT NULL DEFAULT 30
+    CHECK (no_show_window_days > 0);
+
+CREATE INDEX IF NOT EXISTS idx_bookings_user_status ON bookings(user_id, status);
@@ -0,0 +1,22 @@
+-- Правила отмены: бесплатная отмена не позже free_cancel_hours до начала слота,
+-- поздняя отмена либо фиксируется как штраф ('penalty'), либо запрещена ('refuse')
+ALTER TABLE system_settings ADD COLUMN IF NOT EXISTS free_cancel_hours INTEGER NOT NULL DEFAULT 24
+    CHECK (free_cancel_hours >= 0);
+ALTER TABLE system_settings ADD COLUMN IF NOT EXISTS late_cancel_action TEXT NOT NULL DEFAULT 'penalty'
+    CHECK (late_cancel_action IN ('penalty', 'refuse'));
+
+-- Переопределение правил для отдельного объекта; NULL означает глобальное значение
+ALTER TABLE booking_items ADD COLUMN IF NOT EXISTS free_cancel_hours INTEGER
+    CHECK (free_cancel_hours >= 0);
+ALTER TABLE booking_items ADD COLUMN IF NOT EXISTS late_cancel_action TEXT
+    CHECK (late_cancel_action IN ('penalty', 'refuse'));
+
+CREATE TABLE IF NOT EXISTS cancellation_penalties (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    booking_id UUID NOT NULL REFERENCES bookings(id) ON DELETE CASCADE,
+    hours_before_start NUMERIC(8, 2) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_cancellation_penalties_user_id ON cancellation_penalties(user_id);
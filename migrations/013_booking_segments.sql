@@ -0,0 +1,9 @@
+-- Бронирование на несколько подряд идущих слотов: основной слот хранится в bookings.slot_id,
+-- остальные слоты диапазона — в booking_segments
+CREATE TABLE IF NOT EXISTS booking_segments (
+    booking_id UUID NOT NULL REFERENCES bookings(id) ON DELETE CASCADE,
+    slot_id UUID NOT NULL REFERENCES booking_slots(id) ON DELETE CASCADE,
+    PRIMARY KEY (booking_id, slot_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_booking_segments_slot_id ON booking_segments(slot_id);
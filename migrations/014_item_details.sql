@@ -0,0 +1,15 @@
+-- Расширенное описание объекта бронирования: место, категория, метки и произвольные атрибуты
+-- (например {"projector": true, "whiteboard": true, "placement": "indoor"})
+ALTER TABLE booking_items ADD COLUMN IF NOT EXISTS location TEXT;
+ALTER TABLE booking_items ADD COLUMN IF NOT EXISTS category TEXT;
+ALTER TABLE booking_items ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE booking_items ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
+
+UPDATE booking_items SET capacity = 1 WHERE capacity IS NULL OR capacity < 1;
+ALTER TABLE booking_items ALTER COLUMN capacity SET NOT NULL;
+ALTER TABLE booking_items DROP CONSTRAINT IF EXISTS booking_items_capacity_check;
+ALTER TABLE booking_items ADD CONSTRAINT booking_items_capacity_check CHECK (capacity >= 1);
+
+CREATE INDEX IF NOT EXISTS idx_booking_items_category ON booking_items(category);
+CREATE INDEX IF NOT EXISTS idx_booking_items_tags ON booking_items USING GIN (tags);
+CREATE INDEX IF NOT EXISTS idx_booking_items_attributes ON booking_items USING GIN (attributes);
@@ -0,0 +1,59 @@
+-- Иерархия размещения объектов: площадки → здания → этажи/зоны, либо произвольное дерево категорий
+CREATE TABLE IF NOT EXISTS locations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    parent_id UUID REFERENCES locations(id) ON DELETE RESTRICT,
+    name TEXT NOT NULL,
+    kind TEXT NOT NULL DEFAULT 'zone' CHECK (kind IN ('site', 'building', 'floor', 'zone', 'category')),
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_locations_parent_id ON locations(parent_id);
+-- Имена уникальны среди соседей одного родителя
+CREATE UNIQUE INDEX IF NOT EXISTS idx_locations_parent_name
+    ON locations(COALESCE(parent_id, '00000000-0000-0000-0000-000000000000'::uuid), name);
+
+-- Узел и все его предки до корня
+CREATE OR REPLACE FUNCTION location_ancestors(node UUID) RETURNS SETOF UUID LANGUAGE sql STABLE AS $$
+    WITH RECURSIVE ancestors AS (
+        SELECT id, parent_id FROM locations WHERE id = node
+        UNION ALL
+        SELECT l.id, l.parent_id FROM locations l JOIN ancestors a ON l.id = a.parent_id
+    )
+    SELECT id FROM ancestors
+$$;
+
+-- Узел и все его потомки
+CREATE OR REPLACE FUNCTION location_subtree(node UUID) RETURNS SETOF UUID LANGUAGE sql STABLE AS $$
+    WITH RECURSIVE subtree AS (
+        SELECT id FROM locations WHERE id = node
+        UNION ALL
+        SELECT l.id FROM locations l JOIN subtree s ON l.parent_id = s.id
+    )
+    SELECT id FROM subtree
+$$;
+
+-- Часы работы узла; ограничивают все объекты поддерева.
+-- Если у узла нет ни одной записи, он не ограничивает время; день недели без записей считается выходным
+CREATE TABLE IF NOT EXISTS location_hours (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    location_id UUID NOT NULL REFERENCES locations(id) ON DELETE CASCADE,
+    weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),  -- 0 = воскресенье
+    open_time TIME NOT NULL,
+    close_time TIME NOT NULL,
+    CONSTRAINT valid_location_hours CHECK (close_time > open_time)
+);
+
+CREATE INDEX IF NOT EXISTS idx_location_hours_location ON location_hours(location_id, weekday);
+
+-- Менеджеры, ответственные за узел и всё его поддерево
+CREATE TABLE IF NOT EXISTS location_managers (
+    location_id UUID NOT NULL REFERENCES locations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (location_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_location_managers_user_id ON location_managers(user_id);
+
+ALTER TABLE booking_items ADD COLUMN IF NOT EXISTS location_id UUID REFERENCES locations(id) ON DELETE RESTRICT;
+CREATE INDEX IF NOT EXISTS idx_booking_items_location_id ON booking_items(location_id);
@@ -0,0 +1,6 @@
+-- Буферы до и после бронирования (подготовка и уборка). Время слотов остаётся «чистым»:
+-- буферы учитываются при генерации слотов и при проверке пересечения соседних бронирований
+ALTER TABLE booking_items ADD COLUMN IF NOT EXISTS buffer_before_minutes INTEGER NOT NULL DEFAULT 0
+    CHECK (buffer_before_minutes >= 0);
+ALTER TABLE booking_items ADD COLUMN IF NOT EXISTS buffer_after_minutes INTEGER NOT NULL DEFAULT 0
+    CHECK (buffer_after_minutes >= 0);
@@ -0,0 +1,29 @@
+-- Комплекты объектов, которые бронируются вместе (например, переговорная и проектор).
+-- quantity — сколько мест занимает элемент; NULL означает «по числу участников бронирования»
+CREATE TABLE IF NOT EXISTS item_bundles (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name TEXT NOT NULL UNIQUE,
+    description TEXT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS item_bundle_members (
+    bundle_id UUID NOT NULL REFERENCES item_bundles(id) ON DELETE CASCADE,
+    item_id UUID NOT NULL REFERENCES booking_items(id) ON DELETE CASCADE,
+    quantity INTEGER CHECK (quantity > 0),
+    PRIMARY KEY (bundle_id, item_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_item_bundle_members_item_id ON item_bundle_members(item_id);
+
+-- Одно бронирование комплекта объединяет бронирования всех его элементов
+CREATE TABLE IF NOT EXISTS bundle_bookings (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    bundle_id UUID REFERENCES item_bundles(id) ON DELETE SET NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS bundle_booking_id UUID REFERENCES bundle_bookings(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_bookings_bundle_booking_id ON bookings(bundle_booking_id);
@@ -0,0 +1,3 @@
+-- Слоты с бронированиями (в том числе завершёнными и отменёнными) не удаляются, а снимаются
+-- из расписания: удаление каскадом стёрло бы бронирования вместе с их историей
+ALTER TABLE booking_slots ADD COLUMN IF NOT EXISTS removed_at TIMESTAMPTZ;
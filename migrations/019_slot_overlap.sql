@@ -0,0 +1,28 @@
+-- Слоты одного объекта, кроме снятых из расписания, не должны пересекаться по времени. Ограничение откладываемое,
+-- чтобы массовое обновление могло поменять слоты местами в одной транзакции.
+-- Если в базе уже есть пересечения, ограничение не создаётся: их нужно найти через
+-- GET /api/slots/overlaps, исправить, и миграция добавит его при следующем запуске
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'booking_slots_no_overlap') THEN
+        RETURN;
+    END IF;
+
+    IF EXISTS (
+        SELECT 1 FROM booking_slots a
+        JOIN booking_slots b ON a.item_id = b.item_id AND a.date = b.date AND a.id < b.id
+        WHERE a.start_time < b.end_time AND a.end_time > b.start_time
+          AND a.removed_at IS NULL AND b.removed_at IS NULL
+    ) THEN
+        RAISE NOTICE 'booking_slots has overlapping slots, booking_slots_no_overlap is not created';
+        RETURN;
+    END IF;
+
+    ALTER TABLE booking_slots ADD CONSTRAINT booking_slots_no_overlap
+        EXCLUDE USING gist (item_id WITH =, tsrange(date + start_time, date + end_time) WITH &&)
+        WHERE (removed_at IS NULL)
+        DEFERRABLE INITIALLY IMMEDIATE;
+END
+$$;
@@ -0,0 +1,107 @@
+-- Часовые пояса. Дата и время слотов остаются «настенными» в поясе объекта,
+-- а starts_at и ends_at хранят соответствующие моменты времени и пересчитываются триггерами.
+-- Пояс объекта: свой, иначе ближайшего узла иерархии с заданным поясом, иначе пояс сервера БД
+ALTER TABLE booking_items ADD COLUMN IF NOT EXISTS time_zone TEXT;
+ALTER TABLE locations ADD COLUMN IF NOT EXISTS time_zone TEXT;
+-- Пояс, в котором пользователю показываются времена бронирований
+ALTER TABLE users ADD COLUMN IF NOT EXISTS time_zone TEXT;
+
+CREATE OR REPLACE FUNCTION location_time_zone(node UUID) RETURNS TEXT LANGUAGE sql STABLE AS $$
+    WITH RECURSIVE ancestors AS (
+        SELECT id, parent_id, time_zone, 0 AS depth FROM locations WHERE id = node
+        UNION ALL
+        SELECT l.id, l.parent_id, l.time_zone, a.depth + 1 FROM locations l JOIN ancestors a ON l.id = a.parent_id
+    )
+    SELECT time_zone FROM ancestors WHERE time_zone IS NOT NULL ORDER BY depth LIMIT 1
+$$;
+
+CREATE OR REPLACE FUNCTION item_time_zone(item UUID) RETURNS TEXT LANGUAGE sql STABLE AS $$
+    SELECT COALESCE(bi.time_zone, location_time_zone(bi.location_id), current_setting('TimeZone'))
+    FROM booking_items bi WHERE bi.id = item
+$$;
+
+ALTER TABLE booking_slots ADD COLUMN IF NOT EXISTS starts_at TIMESTAMPTZ;
+ALTER TABLE booking_slots ADD COLUMN IF NOT EXISTS ends_at TIMESTAMPTZ;
+
+CREATE OR REPLACE FUNCTION booking_slots_set_instants() RETURNS trigger LANGUAGE plpgsql AS $$
+DECLARE
+    zone TEXT := item_time_zone(NEW.item_id);
+BEGIN
+    NEW.starts_at := (NEW.date + NEW.start_time) AT TIME ZONE zone;
+    NEW.ends_at := (NEW.date + NEW.end_time) AT TIME ZONE zone;
+    RETURN NEW;
+END
+$$;
+
+DROP TRIGGER IF EXISTS booking_slots_instants ON booking_slots;
+CREATE TRIGGER booking_slots_instants BEFORE INSERT OR UPDATE ON booking_slots
+    FOR EACH ROW EXECUTE FUNCTION booking_slots_set_instants();
+
+-- Смена пояса объекта или узла (или перенос в другой узел) пересчитывает моменты слотов
+CREATE OR REPLACE FUNCTION booking_items_zone_changed() RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+    UPDATE booking_slots SET starts_at = NULL WHERE item_id = NEW.id;
+    RETURN NULL;
+END
+$$;
+
+DROP TRIGGER IF EXISTS booking_items_zone ON booking_items;
+CREATE TRIGGER booking_items_zone AFTER UPDATE OF time_zone, location_id ON booking_items
+    FOR EACH ROW
+    WHEN (OLD.time_zone IS DISTINCT FROM NEW.time_zone OR OLD.location_id IS DISTINCT FROM NEW.location_id)
+    EXECUTE FUNCTION booking_items_zone_changed();
+
+CREATE OR REPLACE FUNCTION locations_zone_changed() RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+    UPDATE booking_slots bs SET starts_at = NULL
+    FROM booking_items bi
+    WHERE bs.item_id = bi.id AND bi.location_id IN (SELECT location_subtree(NEW.id));
+    RETURN NULL;
+END
+$$;
+
+DROP TRIGGER IF EXISTS locations_zone ON locations;
+CREATE TRIGGER locations_zone AFTER UPDATE OF time_zone, parent_id ON locations
+    FOR EACH ROW
+    WHEN (OLD.time_zone IS DISTINCT FROM NEW.time_zone OR OLD.parent_id IS DISTINCT FROM NEW.parent_id)
+    EXECUTE FUNCTION locations_zone_changed();
+
+UPDATE booking_slots SET starts_at = NULL WHERE starts_at IS NULL OR ends_at IS NULL;
+ALTER TABLE booking_slots ALTER COLUMN starts_at SET NOT NULL;
+ALTER TABLE booking_slots ALTER COLUMN ends_at SET NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_booking_slots_starts_at ON booking_slots(starts_at);
+
+-- Закрытия хранятся как моменты времени; прежние значения считаются временем сервера БД
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'closures' AND column_name = 'starts_at' AND data_type = 'timestamp without time zone'
+    ) THEN
+        ALTER TABLE closures
+            ALTER COLUMN starts_at TYPE TIMESTAMPTZ USING starts_at AT TIME ZONE current_setting('TimeZone'),
+            ALTER COLUMN ends_at TYPE TIMESTAMPTZ USING ends_at AT TIME ZONE current_setting('TimeZone');
+    END IF;
+END
+$$;
+
+-- Сроки предложений листа ожидания и удержаний мест — тоже моменты времени
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'waitlist_entries' AND column_name = 'offer_expires_at' AND data_type = 'timestamp without time zone'
+    ) THEN
+        ALTER TABLE waitlist_entries
+            ALTER COLUMN offer_expires_at TYPE TIMESTAMPTZ USING offer_expires_at AT TIME ZONE current_setting('TimeZone');
+    END IF;
+
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'slot_holds' AND column_name = 'expires_at' AND data_type = 'timestamp without time zone'
+    ) THEN
+        ALTER TABLE slot_holds
+            ALTER COLUMN expires_at TYPE TIMESTAMPTZ USING expires_at AT TIME ZONE current_setting('TimeZone');
+    END IF;
+END
+$$;
@@ -0,0 +1,19 @@
+-- До учёта вместимости бронирование закрывало слот (is_available = false), а отмена открывала его снова.
+-- Теперь is_available означает только блокировку менеджером, а занятость считается по местам, поэтому
+-- слоты, закрытые прежним кодом, открываются один раз. Отметкой служит комментарий к столбцу.
+-- Слот с бронированиями, который менеджер заблокировал вручную, отличить нельзя: он тоже откроется
+DO $$
+BEGIN
+    IF col_description('booking_slots'::regclass,
+            (SELECT attnum FROM pg_attribute WHERE attrelid = 'booking_slots'::regclass AND attname = 'is_available'))
+        IS NOT DISTINCT FROM 'Slot is blocked by a manager when false; seats are counted from bookings' THEN
+        RETURN;
+    END IF;
+
+    UPDATE booking_slots bs SET is_available = true
+    WHERE bs.is_available = false AND bs.removed_at IS NULL
+      AND EXISTS (SELECT 1 FROM bookings b WHERE b.slot_id = bs.id);
+
+    COMMENT ON COLUMN booking_slots.is_available IS 'Slot is blocked by a manager when false; seats are counted from bookings';
+END
+$$;
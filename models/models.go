@@ -3,6 +3,7 @@ package models
 import (
 	"database/sql"
 	"html/template"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/sessions"
@@ -15,8 +16,28 @@ var (
 	SlotDuration = 60
 	DayStart     = "08:00"
 	DayEnd       = "22:00"
+
+	BookingWindowDays = 30
+	MinBookingHours   = 2
+	MaxDailyBookings  = 3
+
+	WaitlistMode         = "auto"
+	WaitlistOfferMinutes = 30
+	HoldMinutes          = 10
+
+	CheckInRequired      = false
+	CheckInWindowMinutes = 15
+	NoShowGraceMinutes   = 15
+	NoShowLimit          = 3
+	NoShowWindowDays     = 30
+
+	FreeCancelHours  = 24
+	LateCancelAction = "penalty"
 )
 
+// DBLocation — пояс сервера БД (SHOW TimeZone), в котором живут объекты и пользователи без своего пояса
+var DBLocation = time.Local
+
 type User struct {
 	ID        uuid.UUID `json:"id"`
 	Login     string    `json:"login"`
@@ -25,33 +46,303 @@ type User struct {
 	BirthDate string    `json:"birth_date"`
 	Gender    string    `json:"gender"`
 	Role      string    `json:"role"`
+	// TimeZone — пояс, в котором пользователю показываются времена (пусто — пояс сервера)
+	TimeZone string `json:"time_zone"`
 }
 
 type BookingItem struct {
-	ID   uuid.UUID `json:"id"`
-	Name string    `json:"name"`
+	ID                uuid.UUID              `json:"id"`
+	Name              string                 `json:"name"`
+	Description       string                 `json:"description"`
+	Capacity          int                    `json:"capacity"`
+	Location          string                 `json:"location"`
+	Category          string                 `json:"category"`
+	LocationID        *uuid.UUID             `json:"location_id"`
+	Tags              []string               `json:"tags"`
+	Attributes        map[string]interface{} `json:"attributes"`
+	BufferBefore      int                    `json:"buffer_before_minutes"`
+	BufferAfter       int                    `json:"buffer_after_minutes"`
+	RequiresApproval  bool                   `json:"requires_approval"`
+	PendingHoldsSeats bool                   `json:"pending_holds_seats"`
+	// TimeZone — собственный пояс объекта (пусто — наследуется), EffectiveTimeZone — действующий
+	TimeZone          string `json:"time_zone"`
+	EffectiveTimeZone string `json:"effective_time_zone"`
 }
 
 type BookingSlot struct {
-	ID          uuid.UUID `json:"id"`
-	Date        string    `json:"date"`
-	StartTime   string    `json:"start_time"`
-	EndTime     string    `json:"end_time"`
-	ItemID      uuid.UUID `json:"item_id"`
-	IsAvailable bool      `json:"is_available"`
+	ID              uuid.UUID `json:"id"`
+	Date            string    `json:"date"`
+	StartTime       string    `json:"start_time"`
+	EndTime         string    `json:"end_time"`
+	ItemID          uuid.UUID `json:"item_id"`
+	IsAvailable     bool      `json:"is_available"`
+	MaxParticipants int       `json:"max_participants"`
+	RemainingSeats  int       `json:"remaining_seats"`
+	// Моменты начала и конца в RFC 3339 и пояс объекта, в котором заданы date и время
+	StartsAt string `json:"starts_at,omitempty"`
+	EndsAt   string `json:"ends_at,omitempty"`
+	TimeZone string `json:"time_zone,omitempty"`
+}
+
+// SlotChangePlan — изменения слотов объекта при массовом обновлении.
+// Deleted — слоты без бронирований, которые удаляются; Archived — слоты с бронированиями,
+// которые снимаются из расписания, сохраняя бронирования и их историю
+type SlotChangePlan struct {
+	Created          []BookingSlot     `json:"created"`
+	Updated          []BookingSlot     `json:"updated"`
+	Deleted          []BookingSlot     `json:"deleted"`
+	Archived         []BookingSlot     `json:"archived"`
+	AffectedBookings []AffectedBooking `json:"affected_bookings"`
+	DryRun           bool              `json:"dry_run"`
+}
+
+// SlotOverlap — пара пересекающихся по времени слотов одного объекта
+type SlotOverlap struct {
+	ItemID   uuid.UUID   `json:"item_id"`
+	ItemName string      `json:"item_name,omitempty"`
+	Date     string      `json:"date"`
+	First    BookingSlot `json:"first"`
+	Second   BookingSlot `json:"second"`
+}
+
+// AffectedBooking — активное бронирование удаляемого слота
+type AffectedBooking struct {
+	BookingID    uuid.UUID `json:"booking_id"`
+	SlotID       uuid.UUID `json:"slot_id"`
+	UserID       uuid.UUID `json:"user_id"`
+	UserLogin    string    `json:"user_login"`
+	Status       string    `json:"status"`
+	Participants int       `json:"participants"`
 }
 
 type Booking struct {
-	ID        uuid.UUID   `json:"id"`
-	UserID    uuid.UUID   `json:"user_id"`
-	SlotID    uuid.UUID   `json:"slot_id"`
-	CreatedAt string      `json:"created_at"`
-	Slot      BookingSlot `json:"slot"`
-	Item      BookingItem `json:"item"`
+	ID           uuid.UUID   `json:"id"`
+	UserID       uuid.UUID   `json:"user_id"`
+	SlotID       uuid.UUID   `json:"slot_id"`
+	Participants int         `json:"participants"`
+	Status       string      `json:"status"`
+	SeriesID     *uuid.UUID  `json:"series_id"`
+	CancelledAt  *string     `json:"cancelled_at"`
+	CancelReason *string     `json:"cancel_reason"`
+	RejectReason *string     `json:"reject_reason"`
+	CheckedInAt  *string     `json:"checked_in_at"`
+	CreatedAt    string      `json:"created_at"`
+	Slot         BookingSlot `json:"slot"`
+	Item         BookingItem `json:"item"`
 }
 
 type SystemSettings struct {
-	SlotDurationMinutes int    `json:"slot_duration_minutes"`
-	DayStartTime        string `json:"day_start_time"`
-	DayEndTime          string `json:"day_end_time"`
+	SlotDurationMinutes  int    `json:"slot_duration_minutes"`
+	DayStartTime         string `json:"day_start_time"`
+	DayEndTime           string `json:"day_end_time"`
+	BookingWindowDays    int    `json:"booking_window_days"`
+	MinBookingHours      int    `json:"min_booking_hours"`
+	MaxDailyBookings     int    `json:"max_daily_bookings"`
+	WaitlistMode         string `json:"waitlist_mode"`
+	WaitlistOfferMinutes int    `json:"waitlist_offer_minutes"`
+	HoldMinutes          int    `json:"hold_minutes"`
+	CheckInRequired      bool   `json:"check_in_required"`
+	CheckInWindowMinutes int    `json:"check_in_window_minutes"`
+	NoShowGraceMinutes   int    `json:"no_show_grace_minutes"`
+	NoShowLimit          int    `json:"no_show_limit"`
+	NoShowWindowDays     int    `json:"no_show_window_days"`
+	FreeCancelHours      int    `json:"free_cancel_hours"`
+	LateCancelAction     string `json:"late_cancel_action"`
+}
+
+// TimeInterval — интервал времени внутри дня в формате "15:04"
+type TimeInterval struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// DaySchedule — расписание объекта бронирования на один день недели
+type DaySchedule struct {
+	Weekday             int            `json:"weekday"`
+	SlotDurationMinutes int            `json:"slot_duration_minutes"`
+	Open                []TimeInterval `json:"open"`
+	Breaks              []TimeInterval `json:"breaks"`
+}
+
+// Location — узел иерархии размещения: площадка, здание, этаж, зона или категория
+type Location struct {
+	ID         uuid.UUID   `json:"id"`
+	ParentID   *uuid.UUID  `json:"parent_id"`
+	Name       string      `json:"name"`
+	Kind       string      `json:"kind"`
+	TimeZone   string      `json:"time_zone"`
+	ManagerIDs []string    `json:"manager_ids"`
+	Children   []*Location `json:"children"`
+}
+
+// LocationHours — часы работы узла иерархии в один день недели
+type LocationHours struct {
+	Weekday int            `json:"weekday"`
+	Open    []TimeInterval `json:"open"`
+}
+
+// Closure — закрытие одного или всех объектов на интервал времени
+type Closure struct {
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	Kind      string     `json:"kind"`
+	StartsAt  string     `json:"starts_at"`
+	EndsAt    string     `json:"ends_at"`
+	ItemID    *uuid.UUID `json:"item_id"`
+	CreatedAt string     `json:"created_at"`
+}
+
+// BookingConflict — бронирование, попадающее под закрытие или изменение слотов
+type BookingConflict struct {
+	BookingID uuid.UUID `json:"booking_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	UserLogin string    `json:"user_login"`
+	ItemName  string    `json:"item_name"`
+	Date      string    `json:"date"`
+	StartTime string    `json:"start_time"`
+	EndTime   string    `json:"end_time"`
+}
+
+// WaitlistEntry — место пользователя в листе ожидания слота
+type WaitlistEntry struct {
+	ID             uuid.UUID `json:"id"`
+	SlotID         uuid.UUID `json:"slot_id"`
+	Participants   int       `json:"participants"`
+	Status         string    `json:"status"`
+	Position       int       `json:"position"`
+	OfferExpiresAt *string   `json:"offer_expires_at"`
+	Date           string    `json:"date"`
+	StartTime      string    `json:"start_time"`
+	EndTime        string    `json:"end_time"`
+	ItemName       string    `json:"item_name"`
+	CreatedAt      string    `json:"created_at"`
+}
+
+// BookingDetails — бронирование с данными пользователя, объекта и слота для менеджерских списков
+type BookingDetails struct {
+	ID           uuid.UUID `json:"id"`
+	UserID       uuid.UUID `json:"user_id"`
+	UserLogin    string    `json:"user_login"`
+	UserFullName string    `json:"user_full_name"`
+	ItemID       uuid.UUID `json:"item_id"`
+	ItemName     string    `json:"item_name"`
+	SlotID       uuid.UUID `json:"slot_id"`
+	Date         string    `json:"date"`
+	StartTime    string    `json:"start_time"`
+	EndTime      string    `json:"end_time"`
+	Participants int       `json:"participants"`
+	Status       string    `json:"status"`
+	CancelledAt  *string   `json:"cancelled_at"`
+	CancelReason *string   `json:"cancel_reason"`
+	RejectReason *string   `json:"reject_reason"`
+	CheckedInAt  *string   `json:"checked_in_at"`
+	CreatedAt    string    `json:"created_at"`
+}
+
+// BookingSeries — серия повторяющихся бронирований одного объекта
+type BookingSeries struct {
+	ID           uuid.UUID `json:"id"`
+	ItemID       uuid.UUID `json:"item_id"`
+	Rule         string    `json:"rule"`
+	StartDate    string    `json:"start_date"`
+	StartTime    string    `json:"start_time"`
+	Participants int       `json:"participants"`
+	Status       string    `json:"status"`
+	CreatedAt    string    `json:"created_at"`
+}
+
+// SeriesOccurrence — результат бронирования одного повторения серии
+type SeriesOccurrence struct {
+	Date       string            `json:"date"`
+	SlotID     *string           `json:"slot_id,omitempty"`
+	BookingID  *string           `json:"booking_id,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Violations []PolicyViolation `json:"violations,omitempty"`
+}
+
+// AvailabilityWindow — свободный интервал из одного или нескольких подряд идущих слотов объекта
+type AvailabilityWindow struct {
+	Date           string   `json:"date"`
+	StartTime      string   `json:"start_time"`
+	EndTime        string   `json:"end_time"`
+	SlotIDs        []string `json:"slot_ids"`
+	RemainingSeats int      `json:"remaining_seats"`
+}
+
+// ItemAvailability — объект бронирования с подходящими свободными интервалами
+type ItemAvailability struct {
+	ItemID   uuid.UUID            `json:"item_id"`
+	ItemName string               `json:"item_name"`
+	Capacity int                  `json:"capacity"`
+	Fit      int                  `json:"fit"`
+	Windows  []AvailabilityWindow `json:"windows"`
+}
+
+// DayAvailability — сводка доступности за один день для календаря
+type DayAvailability struct {
+	Date           string `json:"date"`
+	TotalSlots     int    `json:"total_slots"`
+	FreeSlots      int    `json:"free_slots"`
+	RemainingSeats int    `json:"remaining_seats"`
+	Closed         bool   `json:"closed"`
+}
+
+// BusyInterval — интервал, когда пользователь занят подтверждённым бронированием.
+// Бронирование и объект видны только менеджерам
+type BusyInterval struct {
+	StartsAt  string     `json:"starts_at"`
+	EndsAt    string     `json:"ends_at"`
+	BookingID *uuid.UUID `json:"booking_id,omitempty"`
+	ItemID    *uuid.UUID `json:"item_id,omitempty"`
+	ItemName  string     `json:"item_name,omitempty"`
+}
+
+// UserFreeBusy — занятость пользователя на интервале времени
+type UserFreeBusy struct {
+	UserID uuid.UUID      `json:"user_id"`
+	Login  string         `json:"login"`
+	Busy   []BusyInterval `json:"busy"`
+}
+
+// ItemBundle — комплект объектов, бронируемых вместе
+type ItemBundle struct {
+	ID          uuid.UUID      `json:"id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Members     []BundleMember `json:"members"`
+}
+
+// BundleMember — объект в составе комплекта. Quantity nil — по числу участников бронирования
+type BundleMember struct {
+	ItemID   uuid.UUID `json:"item_id"`
+	ItemName string    `json:"item_name"`
+	Quantity *int      `json:"quantity"`
+}
+
+// BundleWindow — интервал, свободный во всех объектах комплекта, в поясе первого объекта комплекта
+type BundleWindow struct {
+	Date      string `json:"date"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	TimeZone  string `json:"time_zone"`
+}
+
+// Notification — уведомление пользователя
+type Notification struct {
+	ID        uuid.UUID `json:"id"`
+	Message   string    `json:"message"`
+	CreatedAt string    `json:"created_at"`
+	Read      bool      `json:"read"`
+}
+
+// PolicyViolation описывает нарушенное правило бронирования
+type PolicyViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// CancellationPolicy — действующие для объекта правила отмены бронирований
+type CancellationPolicy struct {
+	FreeCancelHours  int    `json:"free_cancel_hours"`
+	LateCancelAction string `json:"late_cancel_action"`
 }
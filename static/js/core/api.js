@@ -20,12 +20,16 @@ export async function apiRequest(url, method, body = null) {
     const text = await response.text();
 
     if (!response.ok) {
+        let error;
         try {
-            const error = text ? JSON.parse(text) : {};
-            throw new Error(error.error || error.message || 'Ошибка запроса');
+            error = text ? JSON.parse(text) : {};
         } catch {
             throw new Error(text || 'Ошибка запроса');
         }
+        if (Array.isArray(error.violations) && error.violations.length > 0) {
+            throw new Error(error.violations.map(v => v.message).join('; '));
+        }
+        throw new Error(error.error || error.message || 'Ошибка запроса');
     }
 
     try {
@@ -12,6 +12,7 @@ import { initDateManagement } from '../features/date.js';
 import { initBookingManagement } from '../features/booking.js';
 import { initAdminManagement } from '../features/admin.js';
 import { initSlotManagement } from '../features/slot.js';
+import { initAvailabilitySearch } from '../features/search.js';
 
 document.addEventListener('DOMContentLoaded', function() {
     console.log('Booking System initialized');
@@ -23,9 +24,11 @@ document.addEventListener('DOMContentLoaded', function() {
 
     // Инициализация специфичных модулей
     if (document.getElementById('add-user-btn')) initUserManagement();
-    if (document.getElementById('add-item-btn')) initItemManagement();
+    // В админ-панели объектами управляет admin.js
+    if (document.getElementById('add-item-btn') && !document.querySelector('.admin-container')) initItemManagement();
     if (document.querySelector('.date-list')) initDateManagement();
     if (document.querySelector('.booking-list')) initBookingManagement();
+    if (document.getElementById('search-form')) initAvailabilitySearch();
 
     // Главные панели (взаимоисключающие)
     if (document.querySelector('.manager-container')) {
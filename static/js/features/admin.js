@@ -8,6 +8,7 @@ import { showNotification } from '../core/notifications.js';
 export function initAdminManagement() {
     initManagersManagement();
     initItemsManagement();
+    initLocationsManagement();
     initSettingsManagement();
 }
 
@@ -37,9 +38,15 @@ function initItemsManagement() {
         });
     }
 
+    document.getElementById('cancel-edit-item-btn')?.addEventListener('click', (e) => {
+        e.preventDefault();
+        resetItemForm();
+    });
+
     document.querySelectorAll('.item-list li').forEach(item => {
         item.addEventListener('click', async (e) => {
-            if (e.target.classList.contains('delete-btn')) return;
+            if (e.target.classList.contains('delete-btn') || e.target.classList.contains('generate-btn')
+                || e.target.classList.contains('edit-item-btn')) return;
             
             const itemId = item.getAttribute('data-item-id');
             if (itemId) {
@@ -60,6 +67,85 @@ function initItemsManagement() {
             await deleteItem(btn.getAttribute('data-id'));
         });
     });
+
+    document.querySelectorAll('.item-list .edit-item-btn').forEach(btn => {
+        btn.addEventListener('click', async (e) => {
+            e.stopPropagation();
+            await editItem(btn.getAttribute('data-id'));
+        });
+    });
+
+    document.querySelectorAll('.item-list .generate-btn').forEach(btn => {
+        btn.addEventListener('click', async (e) => {
+            e.stopPropagation();
+            await generateSlots(btn.getAttribute('data-id'));
+        });
+    });
+}
+
+function initLocationsManagement() {
+    document.getElementById('add-location-btn')?.addEventListener('click', async (e) => {
+        e.preventDefault();
+        await addLocation();
+    });
+
+    document.querySelectorAll('.location-list li').forEach(row => {
+        const managerIds = (row.getAttribute('data-manager-ids') || '').split(',');
+        row.querySelectorAll('.location-managers option').forEach(option => {
+            option.selected = managerIds.includes(option.value);
+        });
+
+        const locationId = row.getAttribute('data-location-id');
+        row.querySelector('.save-location-managers-btn').addEventListener('click', async () => {
+            const selected = Array.from(row.querySelectorAll('.location-managers option:checked')).map(o => o.value);
+            await saveLocationManagers(locationId, selected);
+        });
+        row.querySelector('.delete-location-btn').addEventListener('click', async () => {
+            await deleteLocation(locationId);
+        });
+    });
+}
+
+async function addLocation() {
+    try {
+        const name = document.getElementById('location-name').value.trim();
+        if (!name) throw new Error('Location name is required');
+
+        const parentId = document.getElementById('location-parent').value;
+        await apiRequest('/api/locations', 'POST', {
+            name,
+            kind: document.getElementById('location-kind').value,
+            parent_id: parentId || null
+        });
+        showNotification('Location created successfully', 'success');
+        location.reload();
+    } catch (error) {
+        console.error('Error creating location:', error);
+        showNotification(error.message || 'Failed to create location', 'error');
+    }
+}
+
+async function saveLocationManagers(locationId, managerIds) {
+    try {
+        await apiRequest(`/api/locations/${locationId}/managers`, 'PUT', { manager_ids: managerIds });
+        showNotification('Managers saved', 'success');
+    } catch (error) {
+        console.error('Error saving managers:', error);
+        showNotification(error.message || 'Failed to save managers', 'error');
+    }
+}
+
+async function deleteLocation(locationId) {
+    if (!confirm('Are you sure you want to delete this location?')) return;
+
+    try {
+        await apiRequest(`/api/locations/${locationId}`, 'DELETE');
+        showNotification('Location deleted successfully', 'success');
+        location.reload();
+    } catch (error) {
+        console.error('Error deleting location:', error);
+        showNotification(error.message || 'Failed to delete location', 'error');
+    }
 }
 
 function initSettingsManagement() {
@@ -165,17 +251,111 @@ async function deleteManager(managerId) {
     }
 }
 
+// parseAttributes разбирает строку вида "projector=true, seats=10, placement=indoor"
+function parseAttributes(text) {
+    const attributes = {};
+    text.split(',').map(pair => pair.trim()).filter(Boolean).forEach(pair => {
+        const [key, ...rest] = pair.split('=');
+        const value = rest.join('=').trim();
+        if (!key.trim()) throw new Error(`Invalid attribute: ${pair}`);
+        if (value === 'true' || value === 'false') {
+            attributes[key.trim()] = value === 'true';
+        } else if (value !== '' && !isNaN(Number(value))) {
+            attributes[key.trim()] = Number(value);
+        } else {
+            attributes[key.trim()] = value;
+        }
+    });
+    return attributes;
+}
+
+function formatAttributes(attributes) {
+    return Object.entries(attributes || {}).map(([key, value]) => `${key}=${value}`).join(', ');
+}
+
+function readItemForm() {
+    const name = document.getElementById('item-name').value.trim();
+    if (!name) throw new Error('Item name is required');
+
+    return {
+        name,
+        description: document.getElementById('item-description').value,
+        capacity: parseInt(document.getElementById('item-capacity').value) || 1,
+        location: document.getElementById('item-location').value,
+        category: document.getElementById('item-category').value,
+        location_id: document.getElementById('item-location-id').value,
+        buffer_before_minutes: parseInt(document.getElementById('item-buffer-before').value) || 0,
+        buffer_after_minutes: parseInt(document.getElementById('item-buffer-after').value) || 0,
+        tags: document.getElementById('item-tags').value.split(',').map(tag => tag.trim()).filter(Boolean),
+        attributes: parseAttributes(document.getElementById('item-attributes').value),
+        requires_approval: document.getElementById('item-requires-approval').checked
+    };
+}
+
+function resetItemForm() {
+    ['item-id', 'item-name', 'item-description', 'item-location', 'item-category', 'item-location-id', 'item-tags', 'item-attributes']
+        .forEach(id => { document.getElementById(id).value = ''; });
+    document.getElementById('item-capacity').value = '1';
+    document.getElementById('item-buffer-before').value = '0';
+    document.getElementById('item-buffer-after').value = '0';
+    document.getElementById('item-requires-approval').checked = false;
+    document.getElementById('add-item-btn').textContent = 'Add Item';
+    document.getElementById('cancel-edit-item-btn').style.display = 'none';
+}
+
+async function editItem(itemId) {
+    try {
+        const item = await apiRequest(`/api/booking-items/${itemId}`, 'GET');
+        document.getElementById('item-id').value = item.id;
+        document.getElementById('item-name').value = item.name;
+        document.getElementById('item-description').value = item.description;
+        document.getElementById('item-capacity').value = item.capacity;
+        document.getElementById('item-location').value = item.location;
+        document.getElementById('item-category').value = item.category;
+        document.getElementById('item-location-id').value = item.location_id || '';
+        document.getElementById('item-buffer-before').value = item.buffer_before_minutes;
+        document.getElementById('item-buffer-after').value = item.buffer_after_minutes;
+        document.getElementById('item-tags').value = item.tags.join(', ');
+        document.getElementById('item-attributes').value = formatAttributes(item.attributes);
+        document.getElementById('item-requires-approval').checked = item.requires_approval;
+        document.getElementById('add-item-btn').textContent = 'Save Item';
+        document.getElementById('cancel-edit-item-btn').style.display = '';
+    } catch (error) {
+        console.error('Error loading item:', error);
+        showNotification(error.message || 'Failed to load item', 'error');
+    }
+}
+
 async function addItem() {
     try {
-        const itemName = document.getElementById('item-name').value;
-        if (!itemName) throw new Error('Item name is required');
+        const item = readItemForm();
+        const itemId = document.getElementById('item-id').value;
 
-        await apiRequest('/api/booking-items', 'POST', { name: itemName });
-        showNotification('Item created successfully', 'success');
+        if (itemId) {
+            await apiRequest(`/api/booking-items/${itemId}`, 'PATCH', item);
+            showNotification('Item updated successfully', 'success');
+        } else {
+            await apiRequest('/api/booking-items', 'POST', item);
+            showNotification('Item created successfully', 'success');
+        }
         location.reload();
     } catch (error) {
-        console.error('Error creating item:', error);
-        showNotification(error.message || 'Failed to create item', 'error');
+        console.error('Error saving item:', error);
+        showNotification(error.message || 'Failed to save item', 'error');
+    }
+}
+
+async function generateSlots(itemId) {
+    try {
+        const from = document.getElementById('generate-from').value;
+        const to = document.getElementById('generate-to').value;
+        if (!from || !to) throw new Error('Select a date range first');
+
+        const result = await apiRequest(`/api/items/${itemId}/slots/generate`, 'POST', { from, to });
+        showNotification(`Slots created: ${result.created}, skipped: ${result.skipped}`, 'success');
+    } catch (error) {
+        console.error('Error generating slots:', error);
+        showNotification(error.message || 'Failed to generate slots', 'error');
     }
 }
 
@@ -197,7 +377,10 @@ async function saveSettings() {
         const settings = {
             slot_duration_minutes: parseInt(document.getElementById('slot-duration').value),
             day_start_time: document.getElementById('day-start').value,
-            day_end_time: document.getElementById('day-end').value
+            day_end_time: document.getElementById('day-end').value,
+            booking_window_days: parseInt(document.getElementById('booking-window').value),
+            min_booking_hours: parseInt(document.getElementById('min-booking-hours').value),
+            max_daily_bookings: parseInt(document.getElementById('max-daily-bookings').value)
         };
 
         await apiRequest('/api/settings', 'POST', settings);
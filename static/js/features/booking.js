@@ -8,17 +8,89 @@ import { showNotification } from '../core/notifications.js';
 export function initBookingManagement() {
     document.querySelectorAll('.cancel-booking-btn').forEach(btn => {
         btn.addEventListener('click', async () => {
-            await cancelBooking(btn.getAttribute('data-booking-id'));
+            await cancelBooking(btn.getAttribute('data-booking-id'), btn.getAttribute('data-late') === 'true');
         });
     });
+
+    document.querySelectorAll('.check-in-btn').forEach(btn => {
+        btn.addEventListener('click', async () => {
+            await checkIn(btn.getAttribute('data-booking-id'));
+        });
+    });
+
+    document.querySelectorAll('.claim-waitlist-btn').forEach(btn => {
+        btn.addEventListener('click', async () => {
+            await claimWaitlistOffer(btn.getAttribute('data-entry-id'));
+        });
+    });
+
+    document.querySelectorAll('.leave-waitlist-btn').forEach(btn => {
+        btn.addEventListener('click', async () => {
+            await leaveWaitlist(btn.getAttribute('data-entry-id'));
+        });
+    });
+
+    document.querySelectorAll('.read-notification-btn').forEach(btn => {
+        btn.addEventListener('click', async () => {
+            await markNotificationRead(btn.getAttribute('data-notification-id'), btn.closest('li'));
+        });
+    });
+}
+
+async function checkIn(bookingId) {
+    try {
+        await apiRequest(`/api/bookings/${bookingId}/check-in`, 'POST');
+        showNotification('Приход отмечен', 'success');
+        location.reload();
+    } catch (error) {
+        console.error('Ошибка:', error);
+        showNotification(error.message, 'error');
+    }
+}
+
+async function claimWaitlistOffer(entryId) {
+    try {
+        await apiRequest(`/api/waitlist/${entryId}/claim`, 'POST');
+        showNotification('Бронирование подтверждено', 'success');
+        location.reload();
+    } catch (error) {
+        console.error('Ошибка:', error);
+        showNotification(error.message, 'error');
+    }
+}
+
+async function leaveWaitlist(entryId) {
+    if (!confirm('Покинуть лист ожидания?')) return;
+
+    try {
+        await apiRequest(`/api/waitlist/${entryId}`, 'DELETE');
+        showNotification('Вы покинули лист ожидания', 'success');
+        location.reload();
+    } catch (error) {
+        console.error('Ошибка:', error);
+        showNotification(error.message, 'error');
+    }
+}
+
+async function markNotificationRead(notificationId, element) {
+    try {
+        await apiRequest(`/api/notifications/${notificationId}/read`, 'POST');
+        element?.remove();
+    } catch (error) {
+        console.error('Ошибка:', error);
+        showNotification(error.message, 'error');
+    }
 }
 
-export async function cancelBooking(bookingId) {
-    if (!confirm('Отменить это бронирование?')) return;
+export async function cancelBooking(bookingId, late = false) {
+    const question = late
+        ? 'Срок бесплатной отмены истёк, отмена будет засчитана как поздняя. Отменить бронирование?'
+        : 'Отменить это бронирование?';
+    if (!confirm(question)) return;
 
     try {
-        await apiRequest(`/api/booking-slots/${bookingId}/cancel`, 'POST');
-        showNotification('Бронирование отменено', 'success');
+        const result = await apiRequest(`/api/booking-slots/${bookingId}/cancel`, 'POST');
+        showNotification(result.penalty ? 'Бронирование отменено с опозданием' : 'Бронирование отменено', 'success');
         location.reload();
     } catch (error) {
         console.error('Ошибка отмены:', error);
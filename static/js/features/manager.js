@@ -10,6 +10,7 @@ let currentItemId = null;
 export function initManagerManagement() {
     initUserManagement();
     initSlotManagement();
+    initBookingConsole();
 }
 
 function initUserManagement() {
@@ -41,6 +42,7 @@ function initSlotManagement() {
 
     document.getElementById('add-slot-btn')?.addEventListener('click', addNewSlot);
     document.getElementById('save-slots-btn')?.addEventListener('click', saveSlots);
+    document.getElementById('generate-slots-btn')?.addEventListener('click', generateSlots);
 }
 
 async function addUser() {
@@ -154,10 +156,34 @@ async function addNewSlot() {
     }
 }
 
+async function generateSlots() {
+    try {
+        const from = document.getElementById('generate-from').value;
+        const to = document.getElementById('generate-to').value;
+        if (!from || !to) throw new Error('Укажите диапазон дат');
+
+        const result = await apiRequest(`/api/items/${currentItemId}/slots/generate`, 'POST', { from, to });
+        showNotification(`Создано слотов: ${result.created}, пропущено: ${result.skipped}`, 'success');
+
+        const slots = await apiRequest(`/api/items/${currentItemId}/slots`, 'GET');
+        renderSlots(slots || []);
+    } catch (error) {
+        console.error('Ошибка:', error);
+        showNotification(error.message, 'error');
+    }
+}
+
 async function deleteSlot(slotId) {
     if (!confirm('Удалить слот?')) return;
     try {
-        await apiRequest(`/api/slots/${slotId}`, 'DELETE');
+        try {
+            await apiRequest(`/api/slots/${slotId}`, 'DELETE');
+        } catch (error) {
+            // На слоте есть активные бронирования: они будут отменены только после подтверждения
+            if (!error.message.includes('confirm_delete')) throw error;
+            if (!confirm(`${error.message.split(';')[0]}. Продолжить?`)) return;
+            await apiRequest(`/api/slots/${slotId}?confirm_delete=true`, 'DELETE');
+        }
         showNotification('Слот удален', 'success');
         const slots = await apiRequest(`/api/items/${currentItemId}/slots`, 'GET');
         renderSlots(slots || []);
@@ -176,4 +202,182 @@ async function saveSlots() {
         console.error('Ошибка:', error);
         showNotification(error.message, 'error');
     }
-}
\ No newline at end of file
+}
+
+function initBookingConsole() {
+    document.getElementById('filter-bookings-btn')?.addEventListener('click', loadBookings);
+    document.getElementById('behalf-date')?.addEventListener('change', loadBehalfSlots);
+    document.getElementById('behalf-item')?.addEventListener('change', loadBehalfSlots);
+    document.getElementById('behalf-book-btn')?.addEventListener('click', bookOnBehalf);
+
+    document.getElementById('manager-booking-list')?.addEventListener('click', async (e) => {
+        const checkInBtn = e.target.closest('.manager-check-in-btn');
+        if (checkInBtn) await checkInAnyBooking(checkInBtn.getAttribute('data-booking-id'));
+        const btn = e.target.closest('.manager-cancel-btn');
+        if (!btn) return;
+        await cancelAnyBooking(btn.getAttribute('data-booking-id'));
+    });
+
+    document.getElementById('refresh-approvals-btn')?.addEventListener('click', loadApprovals);
+    document.getElementById('approval-list')?.addEventListener('click', async (e) => {
+        const approveBtn = e.target.closest('.approve-btn');
+        if (approveBtn) await approveBooking(approveBtn.getAttribute('data-booking-id'));
+        const rejectBtn = e.target.closest('.reject-btn');
+        if (rejectBtn) await rejectBooking(rejectBtn.getAttribute('data-booking-id'));
+    });
+    if (document.getElementById('approval-list')) loadApprovals();
+}
+
+async function loadBookings() {
+    const params = new URLSearchParams();
+    const filters = {
+        item_id: document.getElementById('filter-item').value,
+        user_id: document.getElementById('filter-user').value,
+        from: document.getElementById('filter-from').value,
+        to: document.getElementById('filter-to').value,
+        status: document.getElementById('filter-status').value,
+        location_id: document.getElementById('filter-location').value,
+        mine: document.getElementById('filter-mine').checked ? 'true' : ''
+    };
+    Object.entries(filters).forEach(([key, value]) => {
+        if (value) params.append(key, value);
+    });
+
+    try {
+        const bookings = await apiRequest(`/api/manager/bookings?${params}`, 'GET');
+        renderBookings(bookings || []);
+    } catch (error) {
+        console.error('Ошибка:', error);
+        showNotification(error.message, 'error');
+    }
+}
+
+function renderBookings(bookings) {
+    const list = document.getElementById('manager-booking-list');
+    if (bookings.length === 0) {
+        list.innerHTML = '<li class="no-slots">Нет бронирований</li>';
+        return;
+    }
+
+    list.innerHTML = bookings.map(b => `
+        <li class="booking-${b.status}">
+            <span>${b.date} ${b.start_time} - ${b.end_time}, ${b.item_name}</span>
+            <span>${b.user_login} (${b.participants})</span>
+            <span class="booking-status">${b.status}${b.cancel_reason ? ': ' + b.cancel_reason : ''}${b.reject_reason ? ': ' + b.reject_reason : ''}</span>
+            ${b.checked_in_at ? '<span class="checked-in">checked in</span>' : ''}
+            ${(b.status === 'confirmed' || b.status === 'no_show') && !b.checked_in_at
+                ? `<button class="manager-check-in-btn" data-booking-id="${b.id}">Отметить приход</button>`
+                : ''}
+            ${b.status === 'confirmed' || b.status === 'pending'
+                ? `<button class="manager-cancel-btn" data-booking-id="${b.id}">Отменить</button>`
+                : ''}
+        </li>
+    `).join('');
+}
+
+async function loadBehalfSlots() {
+    const itemId = document.getElementById('behalf-item').value;
+    const date = document.getElementById('behalf-date').value;
+    const select = document.getElementById('behalf-slot');
+    if (!itemId || !date) return;
+
+    try {
+        const slots = await apiRequest(`/api/booking-slots?date=${date}&item_id=${itemId}`, 'GET');
+        select.innerHTML = (slots || []).map(slot => `
+            <option value="${slot.id}">${slot.start_time} - ${slot.end_time} (${slot.remaining_seats})</option>
+        `).join('');
+    } catch (error) {
+        console.error('Ошибка:', error);
+        showNotification(error.message, 'error');
+    }
+}
+
+async function bookOnBehalf() {
+    try {
+        const data = {
+            user_id: document.getElementById('behalf-user').value,
+            slot_id: document.getElementById('behalf-slot').value,
+            participants: parseInt(document.getElementById('behalf-participants').value) || 1
+        };
+        if (!data.user_id || !data.slot_id) throw new Error('Выберите пользователя и слот');
+
+        await apiRequest('/api/manager/bookings', 'POST', data);
+        showNotification('Бронирование создано', 'success');
+        await loadBehalfSlots();
+    } catch (error) {
+        console.error('Ошибка:', error);
+        showNotification(error.message, 'error');
+    }
+}
+
+async function checkInAnyBooking(bookingId) {
+    try {
+        await apiRequest(`/api/manager/bookings/${bookingId}/check-in`, 'POST');
+        showNotification('Приход отмечен', 'success');
+        await loadBookings();
+    } catch (error) {
+        console.error('Ошибка:', error);
+        showNotification(error.message, 'error');
+    }
+}
+
+async function cancelAnyBooking(bookingId) {
+    const reason = prompt('Причина отмены:');
+    if (!reason) return;
+
+    try {
+        await apiRequest(`/api/manager/bookings/${bookingId}/cancel`, 'POST', { reason });
+        showNotification('Бронирование отменено', 'success');
+        await loadBookings();
+    } catch (error) {
+        console.error('Ошибка:', error);
+        showNotification(error.message, 'error');
+    }
+}
+
+async function loadApprovals() {
+    const list = document.getElementById('approval-list');
+    try {
+        const bookings = await apiRequest('/api/manager/approvals', 'GET');
+        if (!bookings || bookings.length === 0) {
+            list.innerHTML = '<li class="no-slots">Нет заявок</li>';
+            return;
+        }
+        list.innerHTML = bookings.map(b => `
+            <li class="booking-pending">
+                <span>${b.date} ${b.start_time} - ${b.end_time}, ${b.item_name}</span>
+                <span>${b.user_login} (${b.participants})</span>
+                <button class="approve-btn" data-booking-id="${b.id}">Подтвердить</button>
+                <button class="reject-btn" data-booking-id="${b.id}">Отклонить</button>
+            </li>
+        `).join('');
+    } catch (error) {
+        console.error('Ошибка:', error);
+        showNotification(error.message, 'error');
+    }
+}
+
+async function approveBooking(bookingId) {
+    try {
+        await apiRequest(`/api/manager/bookings/${bookingId}/approve`, 'POST');
+        showNotification('Заявка подтверждена', 'success');
+        await loadApprovals();
+    } catch (error) {
+        console.error('Ошибка:', error);
+        showNotification(error.message, 'error');
+    }
+}
+
+async function rejectBooking(bookingId) {
+    const reason = prompt('Причина отклонения:');
+    if (reason === null) return;
+
+    try {
+        await apiRequest(`/api/manager/bookings/${bookingId}/reject`, 'POST', { reason });
+        showNotification('Заявка отклонена', 'success');
+        await loadApprovals();
+    } catch (error) {
+        console.error('Ошибка:', error);
+        showNotification(error.message, 'error');
+    }
+}
@@ -0,0 +1,92 @@
+/**
+ * Поиск свободных слотов по всем объектам
+ */
+
+import { apiRequest } from '../core/api.js';
+import { showNotification } from '../core/notifications.js';
+
+export function initAvailabilitySearch() {
+    document.getElementById('search-btn').addEventListener('click', () => search(false));
+    document.getElementById('search-first-btn').addEventListener('click', () => search(true));
+
+    document.getElementById('search-results').addEventListener('click', async (e) => {
+        const btn = e.target.closest('.search-book-btn');
+        if (!btn) return;
+        await bookWindow(btn.dataset);
+    });
+}
+
+function searchParams(first) {
+    const params = new URLSearchParams({
+        from: document.getElementById('search-from').value,
+        to: document.getElementById('search-to').value,
+        participants: document.getElementById('search-capacity').value || '1'
+    });
+    const duration = document.getElementById('search-duration').value;
+    const query = document.getElementById('search-query').value.trim();
+    if (duration) params.append('duration', duration);
+    const category = document.getElementById('search-category').value.trim();
+    const tags = document.getElementById('search-tags').value.trim();
+    if (query) params.append('q', query);
+    if (category) params.append('category', category);
+    if (tags) params.append('tag', tags);
+    if (first) params.append('first', 'true');
+    return params;
+}
+
+async function search(first) {
+    try {
+        if (!document.getElementById('search-from').value || !document.getElementById('search-to').value) {
+            throw new Error('Укажите интервал поиска');
+        }
+        const result = await apiRequest(`/api/search/availability?${searchParams(first)}`, 'GET');
+        renderResults(first ? [result] : result || []);
+    } catch (error) {
+        console.error('Ошибка поиска:', error);
+        showNotification(error.message, 'error');
+    }
+}
+
+function renderResults(items) {
+    const list = document.getElementById('search-results');
+    if (items.length === 0) {
+        list.innerHTML = '<li class="no-slots">Ничего не найдено</li>';
+        return;
+    }
+
+    list.innerHTML = items.map(item => `
+        <li>
+            <strong>${item.item_name}</strong> (мест: ${item.capacity})
+            <ul>
+                ${item.windows.map(win => `
+                    <li>
+                        ${win.date} ${win.start_time} - ${win.end_time}, свободно: ${win.remaining_seats}
+                        <button class="search-book-btn" data-item-id="${item.item_id}" data-date="${win.date}"
+                                data-start="${win.start_time}" data-end="${win.end_time}">Забронировать</button>
+                    </li>
+                `).join('')}
+            </ul>
+        </li>
+    `).join('');
+}
+
+async function bookWindow({ itemId, date, start, end }) {
+    if (!confirm(`Забронировать ${date} ${start} - ${end}?`)) return;
+
+    try {
+        const booking = await apiRequest('/api/bookings/range', 'POST', {
+            item_id: itemId,
+            date,
+            start_time: start,
+            end_time: end,
+            participants: parseInt(document.getElementById('search-capacity').value) || 1
+        });
+        showNotification(booking.status === 'pending'
+            ? 'Заявка отправлена на подтверждение менеджеру'
+            : 'Бронирование успешно', 'success');
+        location.reload();
+    } catch (error) {
+        console.error('Ошибка:', error);
+        showNotification(error.message, 'error');
+    }
+}
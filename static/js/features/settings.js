@@ -23,10 +23,14 @@ async function saveSettings() {
         const duration = parseInt(document.getElementById('slot-duration').value);
         const startTime = document.getElementById('day-start').value;
         const endTime = document.getElementById('day-end').value;
+        const windowDays = parseInt(document.getElementById('booking-window').value);
+        const minHours = parseInt(document.getElementById('min-booking-hours').value);
+        const maxDaily = parseInt(document.getElementById('max-daily-bookings').value);
 
         if (isNaN(duration)) throw new Error('Длительность должна быть числом');
         if (duration <= 0) throw new Error('Длительность должна быть положительной');
         if (!startTime || !endTime) throw new Error('Заполните все поля');
+        if (isNaN(windowDays) || isNaN(minHours) || isNaN(maxDaily)) throw new Error('Правила бронирования должны быть числами');
 
         saveBtn.disabled = true;
         saveBtn.textContent = 'Сохранение...';
@@ -34,7 +38,10 @@ async function saveSettings() {
         const response = await apiRequest('/api/settings', 'POST', {
             slot_duration_minutes: duration,
             day_start_time: startTime,
-            day_end_time: endTime
+            day_end_time: endTime,
+            booking_window_days: windowDays,
+            min_booking_hours: minHours,
+            max_daily_bookings: maxDaily
         });
 
         showNotification(response.message || 'Настройки сохранены', 'success');
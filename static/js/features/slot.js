@@ -17,8 +17,8 @@ export function initSlotManagement() {
 async function loadAvailableSlots(itemId) {
     try {
         const date = new Date().toISOString().split('T')[0];
-        const slots = await apiRequest(`/api/booking-slots?date=${date}&item_id=${itemId}`, 'GET');
-        renderSlots(Array.isArray(slots) ? slots : []);
+        const slots = await apiRequest(`/api/booking-slots?date=${date}&item_id=${itemId}&include_full=true`, 'GET');
+        renderSlots(Array.isArray(slots) ? slots : [], itemId, date);
     } catch (error) {
         console.error('Ошибка:', error);
         showNotification('Ошибка загрузки слотов', 'error');
@@ -26,7 +26,7 @@ async function loadAvailableSlots(itemId) {
     }
 }
 
-function renderSlots(slots) {
+function renderSlots(slots, itemId, date) {
     const container = document.getElementById('slot-list-container');
     if (!container) return;
 
@@ -35,27 +35,106 @@ function renderSlots(slots) {
         : slots.map(slot => `
             <div class="slot-item">
                 <span>${slot.start_time} - ${slot.end_time}</span>
-                <button class="book-btn" data-slot-id="${slot.id}">Забронировать</button>
+                <span class="slot-seats">Свободно мест: ${slot.remaining_seats} из ${slot.max_participants}</span>
+                ${slot.remaining_seats > 0
+                    ? `<button class="book-btn" data-slot-id="${slot.id}" data-seats="${slot.remaining_seats}">Забронировать</button>`
+                    : `<button class="waitlist-btn" data-slot-id="${slot.id}">В лист ожидания</button>`}
             </div>
         `).join('');
 
+    // Бронирование нескольких подряд идущих слотов одним бронированием
+    const free = slots.filter(slot => slot.remaining_seats > 0);
+    if (free.length > 1) {
+        container.innerHTML += `
+            <div class="range-booking">
+                <select id="range-start">${free.map(s => `<option>${s.start_time}</option>`).join('')}</select>
+                <select id="range-end">${free.map(s => `<option>${s.end_time}</option>`).join('')}</select>
+                <button id="book-range-btn">Забронировать диапазон</button>
+            </div>
+        `;
+        document.getElementById('book-range-btn').addEventListener('click', async () => {
+            await bookRange(itemId, date,
+                document.getElementById('range-start').value, document.getElementById('range-end').value);
+        });
+    }
+
     setupEventHandlers();
 }
 
+async function bookRange(itemId, date, startTime, endTime) {
+    if (!confirm(`Забронировать ${startTime} - ${endTime}?`)) return;
+
+    try {
+        const booking = await apiRequest('/api/bookings/range', 'POST', {
+            item_id: itemId,
+            date,
+            start_time: startTime,
+            end_time: endTime,
+            participants: 1
+        });
+        showNotification(booking.status === 'pending'
+            ? 'Заявка отправлена на подтверждение менеджеру'
+            : 'Бронирование успешно', 'success');
+        await loadAvailableSlots(itemId);
+    } catch (error) {
+        console.error('Ошибка:', error);
+        showNotification(error.message, 'error');
+    }
+}
+
 function setupEventHandlers() {
     document.querySelectorAll('.book-btn').forEach(btn => {
         btn.addEventListener('click', async (e) => {
             e.stopPropagation();
-            await bookSlot(btn.getAttribute('data-slot-id'));
+            await bookSlot(btn.getAttribute('data-slot-id'), parseInt(btn.getAttribute('data-seats')));
+        });
+    });
+
+    document.querySelectorAll('.waitlist-btn').forEach(btn => {
+        btn.addEventListener('click', async (e) => {
+            e.stopPropagation();
+            await joinWaitlist(btn.getAttribute('data-slot-id'));
         });
     });
 }
 
-async function bookSlot(slotId) {
-    if (!confirm('Подтвердить бронирование?')) return;
+async function joinWaitlist(slotId) {
     try {
-        await apiRequest(`/api/booking-slots/${slotId}/book`, 'POST');
-        showNotification('Бронирование успешно', 'success');
+        const result = await apiRequest(`/api/booking-slots/${slotId}/waitlist`, 'POST', { participants: 1 });
+        showNotification(`Вы в листе ожидания, позиция: ${result.position}`, 'success');
+    } catch (error) {
+        console.error('Ошибка:', error);
+        showNotification(error.message, 'error');
+    }
+}
+
+async function bookSlot(slotId, seats) {
+    let participants = 1;
+    if (seats > 1) {
+        const answer = prompt(`Количество участников (свободно ${seats}):`, '1');
+        if (answer === null) return;
+        participants = parseInt(answer);
+        if (isNaN(participants) || participants <= 0) {
+            showNotification('Количество участников должно быть положительным числом', 'error');
+            return;
+        }
+    }
+
+    try {
+        // Места удерживаются, пока пользователь подтверждает бронирование
+        const hold = await apiRequest(`/api/booking-slots/${slotId}/hold`, 'POST', { participants });
+        const expiresAt = hold.expires_at.replace('T', ' ').slice(0, 16);
+        if (!confirm(`Места удержаны до ${expiresAt}. Подтвердить бронирование?`)) {
+            await apiRequest(`/api/holds/${hold.id}`, 'DELETE');
+            return;
+        }
+
+        const booking = await apiRequest(`/api/holds/${hold.id}/confirm`, 'POST');
+        if (booking.status === 'pending') {
+            showNotification('Заявка отправлена на подтверждение менеджеру', 'success');
+        } else {
+            showNotification('Бронирование успешно', 'success');
+        }
         
         const activeItem = document.querySelector('.item-list li.active');
         if (activeItem) {